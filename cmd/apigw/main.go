@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/morzhanov/go-otel/api/payment"
 	"github.com/morzhanov/go-otel/internal/apigw"
 	"github.com/morzhanov/go-otel/internal/config"
+	gserver "github.com/morzhanov/go-otel/internal/grpc"
 	"github.com/morzhanov/go-otel/internal/logger"
 	"github.com/morzhanov/go-otel/internal/telemetry"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
@@ -21,6 +25,14 @@ func failOnError(l *zap.Logger, step string, err error) {
 	}
 }
 
+func shutdownTelemetry(l *zap.Logger, t telemetry.Telemetry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		l.Error("failed to flush telemetry", zap.Errors("errors", multierr.Errors(err)))
+	}
+}
+
 func main() {
 	l, err := logger.NewLogger()
 	if err != nil {
@@ -28,11 +40,13 @@ func main() {
 	}
 	c, err := config.NewConfig()
 	failOnError(l, "config", err)
-	t, err := telemetry.NewTelemetry(c.JaegerURL, "apigw", l)
+	t, err := telemetry.NewTelemetry(c, "apigw", l)
 	failOnError(l, "telemetry", err)
+	l = logger.Configure(l, c.LogSpanEvents, t.Baggage().AttributeKeys(), t.Redactor())
+	zap.ReplaceGlobals(l)
 
 	uri := fmt.Sprintf("%s:%s", c.PaymentGRPCurl, c.PaymentGRPCport)
-	conn, err := grpc.Dial(uri, grpc.WithInsecure(), grpc.WithBlock())
+	conn, err := gserver.Dial(uri, t, grpc.WithInsecure(), grpc.WithBlock())
 	failOnError(l, "config", err)
 	client := apigw.NewClient(c.OrderRESTurl, payment.NewPaymentClient(conn))
 	srv := apigw.NewController(client, l, t)
@@ -43,4 +57,5 @@ func main() {
 	log.Println("App successfully started!")
 	<-quit
 	log.Println("received os.Interrupt, exiting...")
+	shutdownTelemetry(l, t)
 }
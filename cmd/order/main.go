@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/morzhanov/go-otel/internal/mq"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/morzhanov/go-otel/internal/config"
 	"github.com/morzhanov/go-otel/internal/logger"
 	"github.com/morzhanov/go-otel/internal/telemetry"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
@@ -23,6 +26,14 @@ func failOnError(l *zap.Logger, step string, err error) {
 	}
 }
 
+func shutdownTelemetry(l *zap.Logger, t telemetry.Telemetry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		l.Error("failed to flush telemetry", zap.Errors("errors", multierr.Errors(err)))
+	}
+}
+
 func main() {
 	l, err := logger.NewLogger()
 	if err != nil {
@@ -30,11 +41,15 @@ func main() {
 	}
 	c, err := config.NewConfig()
 	failOnError(l, "config", err)
-	t, err := telemetry.NewTelemetry(c.JaegerURL, "order", l)
+	t, err := telemetry.NewTelemetry(c, "order", l)
 	failOnError(l, "telemetry", err)
-	m, err := mongodb.NewMongoDB(c.MongoURL)
+	l = logger.Configure(l, c.LogSpanEvents, t.Baggage().AttributeKeys(), t.Redactor())
+	zap.ReplaceGlobals(l)
+	m, err := mongodb.NewMongoDB(c.MongoURL, t)
 	failOnError(l, "mongodb", err)
-	msgq, err := mq.NewMq(c.KafkaURL, c.KafkaTopic)
+	statsInterval, err := mq.StatsInterval(c)
+	failOnError(l, "config", err)
+	msgq, err := mq.NewMq(c.KafkaURL, c.KafkaTopic, statsInterval, t)
 	failOnError(l, "message_queue", err)
 
 	srv := order.NewService(l, t, m, msgq)
@@ -45,4 +60,8 @@ func main() {
 	log.Println("App successfully started!")
 	<-quit
 	log.Println("received os.Interrupt, exiting...")
+	if err := msgq.Close(); err != nil {
+		l.Error("failed to close message queue", zap.Error(err))
+	}
+	shutdownTelemetry(l, t)
 }
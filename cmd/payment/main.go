@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/morzhanov/go-otel/internal/payment"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/morzhanov/go-otel/internal/config"
 	"github.com/morzhanov/go-otel/internal/logger"
 	"github.com/morzhanov/go-otel/internal/telemetry"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +24,14 @@ func failOnError(l *zap.Logger, step string, err error) {
 	}
 }
 
+func shutdownTelemetry(l *zap.Logger, t telemetry.Telemetry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		l.Error("failed to flush telemetry", zap.Errors("errors", multierr.Errors(err)))
+	}
+}
+
 func main() {
 	l, err := logger.NewLogger()
 	if err != nil {
@@ -29,19 +39,29 @@ func main() {
 	}
 	c, err := config.NewConfig()
 	failOnError(l, "config", err)
-	t, err := telemetry.NewTelemetry(c.JaegerURL, "payment", l)
+	t, err := telemetry.NewTelemetry(c, "payment", l)
 	failOnError(l, "telemetry", err)
-	p, err := psql.NewDb(c.PostgresURL)
+	l = logger.Configure(l, c.LogSpanEvents, t.Baggage().AttributeKeys(), t.Redactor())
+	zap.ReplaceGlobals(l)
+	p, err := psql.NewDb(c.PostgresURL, t)
 	failOnError(l, "postgres", err)
 
 	pay := payment.NewPayment(p, t)
 	srv, err := payment.NewController(pay, c, l, t)
 	failOnError(l, "service", err)
 	go srv.Listen(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	grpcSrv := payment.NewServer(c.PaymentGRPCurl, c.PaymentGRPCport, l, pay, t)
+	go grpcSrv.Listen(ctx, cancel)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	log.Println("App successfully started!")
 	<-quit
 	log.Println("received os.Interrupt, exiting...")
+	if err := srv.Close(); err != nil {
+		l.Error("failed to close message queue", zap.Error(err))
+	}
+	shutdownTelemetry(l, t)
 }
@@ -11,6 +11,8 @@ import (
 	"go.uber.org/zap"
 )
 
+const tracerName = "github.com/morzhanov/go-otel/internal/apigw"
+
 type controller struct {
 	rest.BaseController
 	client Client
@@ -20,57 +22,47 @@ type Controller interface {
 	Listen(port string)
 }
 
-func (c *controller) handleHttpErr(ctx *gin.Context, err error) {
-	ctx.String(http.StatusInternalServerError, err.Error())
-	c.BaseController.Logger().Info("error in the REST handler", zap.Error(err))
-}
-
 func (c *controller) handleCreateOrder(ctx *gin.Context) {
-	c.Meter().IncReqCount()
-	t := c.Tracer()("rest")
-	sctx, span := t.Start(ctx, "create-order")
+	t := c.Tracer()(tracerName)
+	sctx, span := t.Start(rest.GetSpanContext(ctx), "create-order")
 	defer span.End()
 
 	d := order.CreateOrderMessage{}
 	if err := c.BaseController.ParseRestBody(ctx, &d); err != nil {
-		c.handleHttpErr(ctx, err)
+		c.HandleRestError(ctx, sctx, err)
 		return
 	}
 	res, err := c.client.CreateOrder(sctx, &d)
 	if err != nil {
-		c.handleHttpErr(ctx, err)
+		c.HandleRestError(ctx, sctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, res)
 }
 
 func (c *controller) handleProcessOrder(ctx *gin.Context) {
-	c.Meter().IncReqCount()
-	t := c.Tracer()("rest")
-	sctx, span := t.Start(ctx, "process-order")
-	ctx.Set("span-context", span.SpanContext())
+	t := c.Tracer()(tracerName)
+	sctx, span := t.Start(rest.GetSpanContext(ctx), "process-order")
 	defer span.End()
 
 	id := ctx.Param("id")
 	res, err := c.client.ProcessOrder(sctx, id)
 	if err != nil {
-		c.handleHttpErr(ctx, err)
+		c.HandleRestError(ctx, sctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
 }
 
 func (c *controller) handleGetPaymentInfo(ctx *gin.Context) {
-	c.Meter().IncReqCount()
-	t := c.Tracer()("rest")
-	sctx, span := t.Start(ctx, "get-payment-info")
-	ctx.Set("span-context", span.SpanContext())
+	t := c.Tracer()(tracerName)
+	sctx, span := t.Start(rest.GetSpanContext(ctx), "get-payment-info")
 	defer span.End()
 
 	orderID := ctx.Param("orderID")
 	res, err := c.client.GetPaymentInfo(sctx, orderID)
 	if err != nil {
-		c.handleHttpErr(ctx, err)
+		c.HandleRestError(ctx, sctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -0,0 +1,95 @@
+package apigw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/morzhanov/go-otel/api/order"
+	"github.com/morzhanov/go-otel/api/payment"
+	"github.com/morzhanov/go-otel/internal/rest"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type fakeClient struct {
+	err error
+}
+
+func (c fakeClient) CreateOrder(ctx context.Context, msg *order.CreateOrderMessage) (*order.OrderMessage, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &order.OrderMessage{Id: "1", Name: msg.Name, Amount: msg.Amount, Status: "new"}, nil
+}
+
+func (c fakeClient) ProcessOrder(ctx context.Context, orderID string) (*order.OrderMessage, error) {
+	return nil, c.err
+}
+
+func (c fakeClient) GetPaymentInfo(ctx context.Context, orderID string) (*payment.PaymentMessage, error) {
+	return nil, c.err
+}
+
+func serve(t *testing.T, client Client, method, target, body string) (*telemetrytest.Telemetry, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewController(client, zap.NewNop(), tel).(*controller)
+	w := httptest.NewRecorder()
+	c.Router().ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return tel, w
+}
+
+func TestCreateOrder(t *testing.T) {
+	tel, w := serve(t, fakeClient{}, http.MethodPost, "/order", `{"name":"book","amount":10}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	tel.AssertKind(t, "POST /order", trace.SpanKindServer)
+	tel.AssertAttribute(t, "POST /order", semconv.HTTPRouteKey.String("/order"))
+	tel.AssertParent(t, "create-order", "POST /order")
+	tel.AssertStatus(t, "POST /order", codes.Unset)
+	tel.Collect(t)
+	if n := tel.HistogramCount(t, meter.HTTPServerDuration, semconv.HTTPRouteKey.String("/order")); n != 1 {
+		t.Errorf("%s count = %d, want 1", meter.HTTPServerDuration, n)
+	}
+}
+
+func TestCreateOrderFails(t *testing.T) {
+	tel, w := serve(t, fakeClient{err: errors.New("order service is down")}, http.MethodPost, "/order", `{}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	var body rest.ErrorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if want := tel.Span(t, "create-order").SpanContext().TraceID().String(); body.TraceID != want {
+		t.Errorf("trace_id = %q, want %q", body.TraceID, want)
+	}
+	tel.AssertStatus(t, "create-order", codes.Error)
+	tel.AssertStatus(t, "POST /order", codes.Error)
+}
+
+func TestCreateOrderRejectsBadBody(t *testing.T) {
+	tel, w := serve(t, fakeClient{}, http.MethodPost, "/order", `{`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	// the client is at fault, so the spans don't fail
+	tel.AssertStatus(t, "create-order", codes.Unset)
+	tel.AssertStatus(t, "POST /order", codes.Unset)
+}
@@ -9,8 +9,8 @@ import (
 
 	"github.com/morzhanov/go-otel/internal/rest"
 
-	"github.com/morzhanov/go-otel/api/grpc/order"
-	"github.com/morzhanov/go-otel/api/grpc/payment"
+	"github.com/morzhanov/go-otel/api/order"
+	"github.com/morzhanov/go-otel/api/payment"
 )
 
 type client struct {
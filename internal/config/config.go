@@ -3,16 +3,45 @@ package config
 import "github.com/spf13/viper"
 
 type Config struct {
-	KafkaURL        string
-	KafkaTopic      string
-	KafkaGroupID    string
-	MongoURL        string
-	PostgresURL     string
-	JaegerURL       string
-	APIGWport       string
-	OrderRESTurl    string
-	PaymentGRPCurl  string
-	PaymentGRPCport string
+	KafkaURL               string
+	KafkaTopic             string
+	KafkaGroupID           string
+	KafkaStatsInterval     string
+	MongoURL               string
+	PostgresURL            string
+	JaegerURL              string
+	OtlpGRPCurl            string
+	OtlpHTTPurl            string
+	TraceFilePath          string
+	TraceExporters         string
+	TraceSampler           string
+	TraceSamplerArg        string
+	TraceSamplerRoutes     string
+	TraceSampleErrors      bool
+	TailSamplingPolicies   string
+	TailSamplingWindow     string
+	TailSamplingMaxTraces  int
+	TailSamplingMaxSpans   int
+	TelemetryAdminAddr     string
+	MetricsAddr            string
+	MetricsPath            string
+	RuntimeMetricsInterval string
+	LogSpanEvents          bool
+	SpanMetrics            bool
+	SpanMetricsMaxSeries   int
+	TracePropagators       string
+	BaggageKeys            string
+	BaggageAttributes      string
+	BaggageHeaders         string
+	RedactKeys             string
+	RedactValues           string
+	RedactHashKey          string
+	ServiceVersion         string
+	Environment            string
+	APIGWport              string
+	OrderRESTurl           string
+	PaymentGRPCurl         string
+	PaymentGRPCport        string
 }
 
 func NewConfig() (config *Config, err error) {
@@ -2,9 +2,15 @@ package event
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/morzhanov/go-otel/internal/logger"
 	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/morzhanov/go-otel/internal/telemetry"
 
@@ -13,6 +19,8 @@ import (
 	"go.uber.org/zap"
 )
 
+const tracerName = "github.com/morzhanov/go-otel/internal/event"
+
 type baseController struct {
 	mq      mq.MQ
 	groupID string
@@ -20,17 +28,22 @@ type baseController struct {
 	tel     telemetry.Telemetry
 }
 
+// Handler processes a message, ctx holds the consumer span.
+type Handler func(ctx context.Context, msg *kafka.Message)
+
 type BaseController interface {
-	Listen(ctx context.Context, processRequest func(*kafka.Message))
+	Listen(ctx context.Context, processRequest Handler)
+	HandleError(ctx context.Context, err error)
 	ConsumerGroupId() string
 	Logger() *zap.Logger
 	Tracer() telemetry.TraceFn
 	Meter() meter.Meter
+	Close() error
 }
 
 func (c *baseController) Listen(
 	ctx context.Context,
-	processRequest func(*kafka.Message),
+	processRequest Handler,
 ) {
 	r := c.mq.CreateReader(c.groupID)
 	for {
@@ -39,7 +52,7 @@ func (c *baseController) Listen(
 			c.log.Error(err.Error())
 			continue
 		}
-		go processRequest(&m)
+		go c.process(&m, processRequest)
 		select {
 		case <-ctx.Done():
 			break
@@ -53,29 +66,69 @@ func (c *baseController) Logger() *zap.Logger       { return c.log }
 func (c *baseController) ConsumerGroupId() string   { return c.groupID }
 func (c *baseController) Tracer() telemetry.TraceFn { return c.tel.Tracer() }
 func (c *baseController) Meter() meter.Meter        { return c.tel.Meter() }
+func (c *baseController) Close() error              { return c.mq.Close() }
 
-func GetSpanContext(msg *kafka.Message) (*context.Context, error) {
-	var h kafka.Header
-	for _, v := range msg.Headers {
-		if v.Key == "span-context" {
-			h = v
-			break
-		}
-	}
-	var sctx context.Context
-	if err := json.Unmarshal(h.Value, &sctx); err != nil {
-		return nil, err
+// extract returns the propagated context of msg with the baggage of the
+// mapped headers, like the REST and gRPC servers do.
+func (c *baseController) extract(msg *kafka.Message) context.Context {
+	carrier := mq.NewHeaderCarrier(msg)
+	return c.tel.Baggage().FromCarrier(c.tel.Propagator().Extract(context.Background(), carrier), carrier)
+}
+
+// process runs processRequest under a consumer span, a child of the producer
+// span, and records the duration exemplar with it.
+func (c *baseController) process(msg *kafka.Message, processRequest Handler) {
+	pctx := c.extract(msg)
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("kafka"),
+		semconv.MessagingDestinationKey.String(msg.Topic),
+		semconv.MessagingKafkaConsumerGroupKey.String(c.groupID),
 	}
-	return &sctx, nil
+	ctx, span := c.tel.Tracer()(tracerName).Start(
+		pctx,
+		fmt.Sprintf("%s process", msg.Topic),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		// the producer span is the parent and is linked too, as the
+		// messaging conventions recommend
+		trace.WithLinks(trace.LinkFromContext(pctx)),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingOperationProcess,
+			semconv.MessagingMessageIDKey.String(strconv.FormatInt(msg.Offset, 10)),
+			semconv.MessagingMessagePayloadSizeBytesKey.Int(len(msg.Value)),
+			semconv.MessagingKafkaPartitionKey.Int(msg.Partition),
+		),
+	)
+	active := c.tel.Meter().UpDownCounter(meter.MessagingConsumerActive)
+	active.Add(ctx, 1, attrs...)
+	start := time.Now()
+	processRequest(ctx, msg)
+	span.End()
+	active.Add(ctx, -1, attrs...)
+	c.tel.Meter().Counter(meter.MessagingConsumerMessages).Add(ctx, 1, attrs...)
+	c.tel.Meter().Histogram(meter.MessagingConsumerDuration).Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+}
+
+// HandleError records err on the consumer span in ctx and logs it. Malformed
+// messages (telemetry.IsClientError) don't fail the span.
+func (c *baseController) HandleError(ctx context.Context, err error) {
+	RecordError(ctx, err)
+	logger.WithContext(ctx, c.log).Error("error during event processing", zap.Error(err))
+}
+
+func RecordError(ctx context.Context, err error) {
+	telemetry.RecordError(trace.SpanFromContext(ctx), err, !telemetry.IsClientError(err))
 }
 
 func NewController(
 	kafkaUrl string,
 	kafkaTopic string,
 	kafkaGroupID string,
+	statsInterval time.Duration,
 	log *zap.Logger,
 	tel telemetry.Telemetry,
 ) (BaseController, error) {
-	msgQ, err := mq.NewMq(kafkaUrl, kafkaTopic)
+	msgQ, err := mq.NewMq(kafkaUrl, kafkaTopic, statsInterval, tel)
 	return &baseController{mq: msgQ, groupID: kafkaGroupID, log: log, tel: tel}, err
 }
@@ -0,0 +1,46 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/mq"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestProcess(t *testing.T) {
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &baseController{groupID: "payments", log: zap.NewNop(), tel: tel}
+
+	msg := &kafka.Message{Topic: "orders", Value: []byte(`{}`)}
+	pctx, producer := tel.Tracer()("test").Start(context.Background(), "orders send")
+	tel.Propagator().Inject(pctx, mq.NewHeaderCarrier(msg))
+	producer.End()
+
+	var handled trace.SpanContext
+	c.process(msg, func(ctx context.Context, _ *kafka.Message) {
+		handled = trace.SpanContextFromContext(ctx)
+	})
+
+	tel.AssertKind(t, "orders process", trace.SpanKindConsumer)
+	tel.AssertParent(t, "orders process", "orders send")
+	consumer := tel.Span(t, "orders process")
+	if !handled.Equal(consumer.SpanContext()) {
+		t.Errorf("handler ran with span %s, want the consumer span %s", handled.SpanID(), consumer.SpanContext().SpanID())
+	}
+	if links := consumer.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != producer.SpanContext().SpanID() {
+		t.Errorf("consumer span links %v, want the producer span", links)
+	}
+	tel.Collect(t)
+	if n := tel.HistogramCount(t, meter.MessagingConsumerDuration, semconv.MessagingDestinationKey.String("orders")); n != 1 {
+		t.Errorf("%s count = %d, want 1", meter.MessagingConsumerDuration, n)
+	}
+}
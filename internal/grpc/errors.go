@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// clientCodes are the status codes caused by the caller, they don't fail
+// server spans.
+var clientCodes = map[codes.Code]bool{
+	codes.Canceled:           true,
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.PermissionDenied:   true,
+	codes.Unauthenticated:    true,
+	codes.FailedPrecondition: true,
+	codes.Aborted:            true,
+	codes.OutOfRange:         true,
+}
+
+// Status converts a handler error into a gRPC status, errors marked with
+// telemetry.NotFoundError become NotFound and with telemetry.ClientError
+// InvalidArgument.
+func Status(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	if telemetry.IsNotFound(err) {
+		return status.New(codes.NotFound, err.Error())
+	}
+	if telemetry.IsClientError(err) {
+		return status.New(codes.InvalidArgument, err.Error())
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// downstreamError is the error of a call whose server reported its trace in
+// the traceresponse header, it keeps the gRPC status of the wrapped error.
+type downstreamError struct {
+	err     error
+	traceID string
+}
+
+func (e *downstreamError) Error() string              { return e.err.Error() }
+func (e *downstreamError) Unwrap() error              { return e.err }
+func (e *downstreamError) GRPCStatus() *status.Status { return Status(e.err) }
+func (e *downstreamError) DownstreamTraceID() string  { return e.traceID }
+
+// withTraceResponse adds the trace ID of the traceresponse header in md to
+// err.
+func withTraceResponse(err error, md metadata.MD) error {
+	v := md.Get(telemetry.TraceResponseHeader)
+	if err == nil || len(v) == 0 {
+		return err
+	}
+	if id := telemetry.ParseTraceResponse(v[0]); id != "" {
+		return &downstreamError{err: err, traceID: id}
+	}
+	return err
+}
+
+// RecordError records err on the server span in ctx.
+func RecordError(ctx context.Context, err error) {
+	recordError(trace.SpanFromContext(ctx), trace.SpanKindServer, err)
+}
+
+func recordError(span trace.Span, kind trace.SpanKind, err error) {
+	if err == nil {
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(codes.OK)))
+		return
+	}
+	s := Status(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+	fault := kind == trace.SpanKindClient || !clientCodes[s.Code()]
+	telemetry.RecordError(span, s.Err(), fault)
+}
@@ -0,0 +1,278 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const tracerName = "github.com/morzhanov/go-otel/internal/grpc"
+
+type metadataCarrier struct {
+	md *metadata.MD
+}
+
+func (c metadataCarrier) Get(key string) string {
+	v := c.md.Get(key)
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	c.md.Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, c.md.Len())
+	for k := range *c.md {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type interceptor struct {
+	tracer   trace.Tracer
+	prop     propagation.TextMapPropagator
+	baggage  *telemetry.Baggage
+	kind     trace.SpanKind
+	requests meter.Counter
+	duration meter.Histogram
+}
+
+func newInterceptor(tel telemetry.Telemetry, kind trace.SpanKind) *interceptor {
+	i := &interceptor{tracer: tel.Tracer()(tracerName), prop: tel.Propagator(), baggage: tel.Baggage(), kind: kind}
+	if kind == trace.SpanKindClient {
+		i.duration = tel.Meter().Histogram(meter.RPCClientDuration)
+		return i
+	}
+	i.requests = tel.Meter().Counter(meter.RPCServerRequests)
+	i.duration = tel.Meter().Histogram(meter.RPCServerDuration)
+	return i
+}
+
+func rpcAttributes(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimLeft(fullMethod, "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(parts[0]), semconv.RPCMethodKey.String(parts[1]))
+	}
+	return name, attrs
+}
+
+func peerAttributes(ctx context.Context) []attribute.KeyValue {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{semconv.NetPeerIPKey.String(host)}
+	if n, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(n))
+	}
+	return attrs
+}
+
+func (i *interceptor) finish(ctx context.Context, span trace.Span, start time.Time, attrs []attribute.KeyValue, err error) {
+	code := grpccodes.OK
+	if err != nil {
+		code = Status(err).Code()
+	}
+	attrs = append(attrs, semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	recordError(span, i.kind, err)
+	span.End()
+	if i.requests != nil {
+		i.requests.Add(ctx, 1, attrs...)
+	}
+	i.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+}
+
+func (i *interceptor) startServer(ctx context.Context, fullMethod string) (context.Context, trace.Span, []attribute.KeyValue) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	ctx = i.prop.Extract(ctx, metadataCarrier{md: &md})
+	ctx = i.baggage.FromCarrier(ctx, metadataCarrier{md: &md})
+	name, attrs := rpcAttributes(fullMethod)
+	ctx, span := i.tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(peerAttributes(ctx)...),
+	)
+	return ctx, span, attrs
+}
+
+// traceResponse is the header telling the caller which trace served the call.
+func traceResponse(span trace.Span) metadata.MD {
+	if sc := span.SpanContext(); sc.IsValid() {
+		return metadata.Pairs(telemetry.TraceResponseHeader, telemetry.TraceResponse(sc))
+	}
+	return nil
+}
+
+func (i *interceptor) startClient(ctx context.Context, fullMethod string) (context.Context, trace.Span, []attribute.KeyValue) {
+	name, attrs := rpcAttributes(fullMethod)
+	ctx, span := i.tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	i.prop.Inject(ctx, metadataCarrier{md: &md})
+	return metadata.NewOutgoingContext(ctx, md), span, attrs
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	once   sync.Once
+	finish func(error)
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() { s.finish(err) })
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil && err != io.EOF {
+		md, _ := s.ClientStream.Header()
+		err = withTraceResponse(err, md)
+	}
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.desc.ServerStreams:
+		s.end(nil)
+	}
+	return err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func UnaryServerInterceptor(tel telemetry.Telemetry) grpc.UnaryServerInterceptor {
+	i := newInterceptor(tel, trace.SpanKindServer)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		sctx, span, attrs := i.startServer(ctx, info.FullMethod)
+		if md := traceResponse(span); md != nil {
+			_ = grpc.SetHeader(sctx, md)
+		}
+		res, err := handler(sctx, req)
+		if err != nil {
+			err = Status(err).Err()
+		}
+		i.finish(sctx, span, start, attrs, err)
+		return res, err
+	}
+}
+
+func StreamServerInterceptor(tel telemetry.Telemetry) grpc.StreamServerInterceptor {
+	i := newInterceptor(tel, trace.SpanKindServer)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		sctx, span, attrs := i.startServer(ss.Context(), info.FullMethod)
+		if md := traceResponse(span); md != nil {
+			_ = ss.SetHeader(md)
+		}
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: sctx})
+		if err != nil {
+			err = Status(err).Err()
+		}
+		i.finish(sctx, span, start, attrs, err)
+		return err
+	}
+}
+
+func UnaryClientInterceptor(tel telemetry.Telemetry) grpc.UnaryClientInterceptor {
+	i := newInterceptor(tel, trace.SpanKindClient)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		sctx, span, attrs := i.startClient(ctx, method)
+		span.SetAttributes(semconv.NetPeerNameKey.String(cc.Target()))
+		var header metadata.MD
+		err := invoker(sctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+		i.finish(sctx, span, start, attrs, err)
+		return withTraceResponse(err, header)
+	}
+}
+
+func StreamClientInterceptor(tel telemetry.Telemetry) grpc.StreamClientInterceptor {
+	i := newInterceptor(tel, trace.SpanKindClient)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		sctx, span, attrs := i.startClient(ctx, method)
+		span.SetAttributes(semconv.NetPeerNameKey.String(cc.Target()))
+		cs, err := streamer(sctx, desc, cc, method, opts...)
+		if err != nil {
+			i.finish(sctx, span, start, attrs, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream: cs,
+			desc:         desc,
+			finish:       func(err error) { i.finish(sctx, span, start, attrs, err) },
+		}, nil
+	}
+}
+
+func Dial(url string, tel telemetry.Telemetry, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(
+		opts,
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(tel)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(tel)),
+	)
+	return grpc.Dial(url, opts...)
+}
@@ -13,12 +13,14 @@ import (
 
 type baseServer struct {
 	url string
+	srv *grpc.Server
 	log *zap.Logger
 	tel telemetry.Telemetry
 }
 
 type BaseServer interface {
 	Listen(ctx context.Context, cancel context.CancelFunc, server *grpc.Server)
+	Server() *grpc.Server
 	Logger() *zap.Logger
 	Tracer() telemetry.TraceFn
 	Meter() meter.Meter
@@ -46,10 +48,16 @@ func (s *baseServer) Listen(ctx context.Context, cancel context.CancelFunc, serv
 	}
 }
 
+func (s *baseServer) Server() *grpc.Server      { return s.srv }
 func (s *baseServer) Logger() *zap.Logger       { return s.log }
 func (s *baseServer) Tracer() telemetry.TraceFn { return s.tel.Tracer() }
 func (s *baseServer) Meter() meter.Meter        { return s.tel.Meter() }
 
-func NewServer(url string, log *zap.Logger, tel telemetry.Telemetry) BaseServer {
-	return &baseServer{log: log, url: url, tel: tel}
+func NewServer(url string, log *zap.Logger, tel telemetry.Telemetry, opts ...grpc.ServerOption) BaseServer {
+	opts = append(
+		opts,
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(tel)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(tel)),
+	)
+	return &baseServer{log: log, url: url, tel: tel, srv: grpc.NewServer(opts...)}
 }
@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+	TraceFlagsKey = "trace_flags"
+)
+
+type ctxKey struct{}
+
+// NewContext stores the logger in the context, FromContext picks it up later.
+func NewContext(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, log)
+}
+
+// FromContext returns the logger stored in the context (or the global zap
+// logger) with trace correlation fields of the current span.
+func FromContext(ctx context.Context) *zap.Logger {
+	log, ok := ctx.Value(ctxKey{}).(*zap.Logger)
+	if !ok {
+		log = zap.L()
+	}
+	return WithContext(ctx, log)
+}
+
+// WithContext adds trace_id, span_id and trace_flags of the span in ctx to the
+// logger. If the logger was built with WithSpanEvents, error entries are also
+// recorded as events on that span, with WithBaggageFields the chosen baggage
+// entries are added as fields.
+func WithContext(ctx context.Context, log *zap.Logger) *zap.Logger {
+	span := trace.SpanFromContext(ctx)
+	sc := span.SpanContext()
+	var fields []zap.Field
+	log = log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		cc, ok := c.(*contextCore)
+		if !ok {
+			return c
+		}
+		bg := baggage.FromContext(ctx)
+		for _, k := range cc.baggageKeys {
+			if m := bg.Member(k); m.Key() != "" {
+				fields = append(fields, zap.String(k, m.Value()))
+			}
+		}
+		if !sc.IsValid() {
+			return c
+		}
+		ncc := *cc
+		ncc.span = span
+		return &ncc
+	}))
+	if sc.IsValid() {
+		fields = append(fields,
+			zap.String(TraceIDKey, sc.TraceID().String()),
+			zap.String(SpanIDKey, sc.SpanID().String()),
+			zap.String(TraceFlagsKey, sc.TraceFlags().String()),
+		)
+	}
+	if len(fields) == 0 {
+		return log
+	}
+	return log.With(fields...)
+}
+
+func wrapContextCore(c zapcore.Core, fn func(cc *contextCore)) zapcore.Core {
+	cc, ok := c.(*contextCore)
+	if ok {
+		ncc := *cc
+		cc = &ncc
+	} else {
+		cc = &contextCore{Core: c}
+	}
+	fn(cc)
+	return cc
+}
+
+// WithSpanEvents mirrors error level entries of context loggers as span events.
+func WithSpanEvents() zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return wrapContextCore(c, func(cc *contextCore) { cc.spanEvents = true })
+	})
+}
+
+// WithBaggageFields adds the given baggage entries to context loggers.
+func WithBaggageFields(keys ...string) zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return wrapContextCore(c, func(cc *contextCore) { cc.baggageKeys = keys })
+	})
+}
+
+// Configure applies the context logging options, redactor may be nil.
+func Configure(log *zap.Logger, spanEvents bool, baggageKeys []string, redactor Redactor) *zap.Logger {
+	if spanEvents {
+		log = log.WithOptions(WithSpanEvents())
+	}
+	if len(baggageKeys) > 0 {
+		log = log.WithOptions(WithBaggageFields(baggageKeys...))
+	}
+	if redactor != nil && redactor.Enabled() {
+		log = log.WithOptions(WithRedaction(redactor))
+	}
+	return log
+}
+
+// contextCore carries the settings used by WithContext and, once bound to a
+// span, records error entries as span events.
+type contextCore struct {
+	zapcore.Core
+	spanEvents  bool
+	baggageKeys []string
+	span        trace.Span
+	fields      []zapcore.Field
+}
+
+func (c *contextCore) With(fields []zapcore.Field) zapcore.Core {
+	ncc := *c
+	ncc.Core = c.Core.With(fields)
+	ncc.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &ncc
+}
+
+func (c *contextCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.spanEvents && c.span != nil && e.Level >= zapcore.ErrorLevel && c.span.IsRecording() {
+		ce = ce.AddCore(e, c)
+	}
+	return c.Core.Check(e, ce)
+}
+
+func (c *contextCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("log.severity", e.Level.String()),
+		attribute.String("log.message", e.Message),
+	}
+	for k, v := range enc.Fields {
+		switch k {
+		case TraceIDKey, SpanIDKey, TraceFlagsKey:
+			continue
+		}
+		attrs = append(attrs, attribute.String(k, toString(v)))
+	}
+	c.span.AddEvent("log", trace.WithAttributes(attrs...))
+	return nil
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redactor rewrites a field value, false means the field has to be dropped.
+type Redactor interface {
+	Enabled() bool
+	Redact(kv attribute.KeyValue) (attribute.KeyValue, bool)
+}
+
+// WithRedaction applies the redaction rules to every field before it is
+// written.
+func WithRedaction(r Redactor) zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return wrapContextCore(c, func(cc *contextCore) { cc.Core = &redactCore{Core: cc.Core, r: r} })
+	})
+}
+
+type redactCore struct {
+	zapcore.Core
+	r Redactor
+}
+
+func (c *redactCore) redact(fields []zapcore.Field) []zapcore.Field {
+	res := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.SkipType, zapcore.NamespaceType:
+			res = append(res, f)
+			continue
+		}
+		var v string
+		if f.Type == zapcore.StringType {
+			v = f.String
+		} else {
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			v = toString(enc.Fields[f.Key])
+		}
+		kv, ok := c.r.Redact(attribute.String(f.Key, v))
+		switch {
+		case !ok:
+		case kv.Value.AsString() == v:
+			res = append(res, f)
+		default:
+			res = append(res, zap.String(f.Key, kv.Value.AsString()))
+		}
+	}
+	return res
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+// writeErrors collects the write errors a CheckedEntry reports to its
+// ErrorOutput.
+type writeErrors struct {
+	err error
+}
+
+func (w *writeErrors) Write(p []byte) (int, error) {
+	w.err = multierr.Append(w.err, errors.New(strings.TrimSpace(string(p))))
+	return len(p), nil
+}
+
+func (w *writeErrors) Sync() error { return nil }
+
+// Write hands the redacted fields to the cores of the wrapped one which accept
+// the entry, so that their own level filters still apply, and returns their
+// write errors.
+func (c *redactCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	inner := c.Core.Check(e, nil)
+	if inner == nil {
+		return nil
+	}
+	errs := &writeErrors{}
+	inner.ErrorOutput = errs
+	inner.Write(c.redact(fields)...)
+	return errs.err
+}
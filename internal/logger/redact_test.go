@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type maskRedactor struct{}
+
+func (maskRedactor) Enabled() bool { return true }
+
+func (maskRedactor) Redact(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	if kv.Key == "email" {
+		return kv.Key.String("****"), true
+	}
+	return kv, true
+}
+
+type failingSyncer struct{}
+
+func (failingSyncer) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+func (failingSyncer) Sync() error               { return nil }
+
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := zap.New(core, WithRedaction(maskRedactor{}))
+	l.Info("signed up", zap.String("email", "jane@example.com"), zap.Int("age", 30))
+	l.Debug("filtered by the inner core", zap.String("email", "jane@example.com"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["email"] != "****" || fields["age"] != int64(30) {
+		t.Errorf("fields %v, want a masked email and the age", fields)
+	}
+}
+
+func TestRedactCoreWriteError(t *testing.T) {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := &redactCore{Core: zapcore.NewCore(enc, failingSyncer{}, zap.InfoLevel), r: maskRedactor{}}
+	if err := core.Write(zapcore.Entry{Level: zap.InfoLevel, Message: "msg"}, nil); err == nil {
+		t.Fatal("Write returned no error for a failing inner core")
+	}
+}
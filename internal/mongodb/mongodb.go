@@ -3,12 +3,21 @@ package mongodb
 import (
 	"context"
 
+	"github.com/morzhanov/go-otel/internal/telemetry"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func NewMongoDB(uri string) (*mongo.Collection, error) {
-	clientOptions := options.Client().ApplyURI(uri)
+func NewMongoDB(uri string, tel telemetry.Telemetry) (*mongo.Collection, error) {
+	m, err := newMonitor(tel)
+	if err != nil {
+		return nil, err
+	}
+	clientOptions := options.Client().
+		ApplyURI(uri).
+		SetMonitor(m.commandMonitor()).
+		SetPoolMonitor(m.poolMonitor())
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,179 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/morzhanov/go-otel/internal/mongodb"
+
+type spanKey struct {
+	connectionID string
+	requestID    int64
+}
+
+type inflight struct {
+	span  trace.Span
+	attrs []attribute.KeyValue
+}
+
+type connKey struct {
+	address string
+	id      uint64
+}
+
+type monitor struct {
+	tracer   trace.Tracer
+	duration meter.Histogram
+	failures meter.Counter
+	spans    sync.Map
+
+	mu sync.Mutex
+	// conns tells for every open connection if it is checked out
+	conns map[connKey]bool
+	pools map[string]bool
+}
+
+// peerAttributes parses the driver connection ID, which looks like
+// "host:port[-N]".
+func peerAttributes(connectionID string) []attribute.KeyValue {
+	if i := strings.Index(connectionID, "["); i >= 0 {
+		connectionID = connectionID[:i]
+	}
+	host, port, err := net.SplitHostPort(connectionID)
+	if err != nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{semconv.NetPeerNameKey.String(host)}
+	if n, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(n))
+	}
+	return attrs
+}
+
+func (m *monitor) started(ctx context.Context, e *event.CommandStartedEvent) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemMongoDB,
+		semconv.DBNameKey.String(e.DatabaseName),
+		semconv.DBOperationKey.String(e.CommandName),
+	}
+	name := e.CommandName
+	if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+		attrs = append(attrs, semconv.DBMongoDBCollectionKey.String(coll))
+		name = coll + "." + e.CommandName
+	}
+	_, span := m.tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(peerAttributes(e.ConnectionID)...),
+		trace.WithAttributes(semconv.DBStatementKey.String(RedactStatement(e.Command))),
+	)
+	m.spans.Store(spanKey{e.ConnectionID, e.RequestID}, inflight{span: span, attrs: attrs})
+}
+
+func (m *monitor) finished(ctx context.Context, e *event.CommandFinishedEvent, failure string) {
+	v, ok := m.spans.LoadAndDelete(spanKey{e.ConnectionID, e.RequestID})
+	if !ok {
+		return
+	}
+	f := v.(inflight)
+	if failure != "" {
+		telemetry.RecordError(f.span, errors.New(failure), true)
+	}
+	f.span.End()
+	m.duration.Record(trace.ContextWithSpan(ctx, f.span), float64(e.DurationNanos)/1e6, f.attrs...)
+}
+
+func (m *monitor) commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: m.started,
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			m.finished(ctx, &e.CommandFinishedEvent, "")
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			m.finished(ctx, &e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
+
+// observeUsage reports the connections of every pool by state, pools whose
+// connections are all closed report zeroes.
+func (m *monitor) observeUsage(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for address := range m.pools {
+		var used, idle int
+		for k, u := range m.conns {
+			switch {
+			case k.address != address:
+			case u:
+				used++
+			default:
+				idle++
+			}
+		}
+		attrs := []attribute.KeyValue{semconv.DBSystemMongoDB, attribute.String("pool.name", address)}
+		observe(float64(used), append(attrs, attribute.String("state", "used"))...)
+		observe(float64(idle), append(attrs, attribute.String("state", "idle"))...)
+	}
+}
+
+// poolEvent keeps the state of every connection. PoolCleared only marks the
+// connections stale, the pool reports each of them with ConnectionClosed
+// when it drops them.
+func (m *monitor) poolEvent(e *event.PoolEvent) {
+	key := connKey{address: e.Address, id: e.ConnectionID}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.Type {
+	case event.ConnectionCreated, event.ConnectionReturned:
+		m.pools[e.Address] = true
+		m.conns[key] = false
+	case event.GetSucceeded:
+		m.pools[e.Address] = true
+		m.conns[key] = true
+	case event.ConnectionClosed:
+		delete(m.conns, key)
+	case event.PoolClosedEvent:
+		for k := range m.conns {
+			if k.address == e.Address {
+				delete(m.conns, k)
+			}
+		}
+	case event.GetFailed:
+		m.failures.Add(context.Background(), 1, semconv.DBSystemMongoDB,
+			attribute.String("pool.name", e.Address), attribute.String("reason", e.Reason))
+	}
+}
+
+func (m *monitor) poolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.poolEvent}
+}
+
+func newMonitor(tel telemetry.Telemetry) (*monitor, error) {
+	m := &monitor{
+		tracer:   tel.Tracer()(tracerName),
+		duration: tel.Meter().Histogram(meter.DBClientDuration),
+		failures: tel.Meter().Counter(meter.DBClientConnectionsCheckoutFails),
+		conns:    map[connKey]bool{},
+		pools:    map[string]bool{},
+	}
+	if err := tel.Meter().Gauge(meter.DBClientConnectionsUsage, m.observeUsage); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestPoolUsage(t *testing.T) {
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMonitor(tel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const addr = "mongo:27017"
+	for _, e := range []event.PoolEvent{
+		{Type: event.ConnectionCreated, Address: addr, ConnectionID: 1},
+		{Type: event.ConnectionCreated, Address: addr, ConnectionID: 2},
+		{Type: event.GetSucceeded, Address: addr, ConnectionID: 1},
+		{Type: event.GetSucceeded, Address: addr, ConnectionID: 2},
+		{Type: event.ConnectionReturned, Address: addr, ConnectionID: 2},
+		// a checked out connection is closed, e.g. after a network error
+		{Type: event.PoolCleared, Address: addr},
+		{Type: event.ConnectionClosed, Address: addr, ConnectionID: 1},
+		{Type: event.ConnectionClosed, Address: addr, ConnectionID: 1},
+	} {
+		e := e
+		m.poolEvent(&e)
+	}
+	tel.Collect(t)
+	usage := func(state string) float64 {
+		return tel.MetricValue(t, meter.DBClientConnectionsUsage, attribute.String("state", state))
+	}
+	if used, idle := usage("used"), usage("idle"); used != 0 || idle != 1 {
+		t.Fatalf("used = %v, idle = %v, want 0 and 1", used, idle)
+	}
+
+	m.poolEvent(&event.PoolEvent{Type: event.PoolClosedEvent, Address: addr})
+	tel.Collect(t)
+	if idle := usage("idle"); idle != 0 {
+		t.Fatalf("idle after the pool closed = %v, want 0", idle)
+	}
+}
+
+func TestCommandFailed(t *testing.T) {
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMonitor(tel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := m.commandMonitor()
+	cm.Started(context.Background(), &event.CommandStartedEvent{
+		Command:      bson.Raw(bsoncore.BuildDocument(nil, bsoncore.AppendStringElement(nil, "find", "orders"))),
+		DatabaseName: "shop",
+		CommandName:  "find",
+		RequestID:    1,
+		ConnectionID: "mongo:27017[-1]",
+	})
+	cm.Failed(context.Background(), &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1, ConnectionID: "mongo:27017[-1]"},
+		Failure:              "(Unauthorized) not authorized on shop",
+	})
+	tel.AssertStatus(t, "orders.find", codes.Error)
+	if events := tel.Span(t, "orders.find").Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("orders.find events %v, want one exception", events)
+	}
+}
@@ -0,0 +1,45 @@
+package mongodb
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// RedactStatement renders the document as JSON keeping field names and
+// operators but replacing every value with "?".
+func RedactStatement(doc bson.Raw) string {
+	b := &strings.Builder{}
+	writeRedacted(b, bson.RawValue{Type: bsontype.EmbeddedDocument, Value: doc})
+	return b.String()
+}
+
+func writeRedacted(b *strings.Builder, v bson.RawValue) {
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elems, _ := v.Document().Elements()
+		b.WriteByte('{')
+		for i, e := range elems {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "%q: ", e.Key())
+			writeRedacted(b, e.Value())
+		}
+		b.WriteByte('}')
+	case bsontype.Array:
+		values, _ := v.Array().Values()
+		b.WriteByte('[')
+		for i, e := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeRedacted(b, e)
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteString(`"?"`)
+	}
+}
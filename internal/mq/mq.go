@@ -3,14 +3,26 @@ package mq
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/morzhanov/go-otel/internal/telemetry"
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
 )
 
+const tracerName = "github.com/morzhanov/go-otel/internal/mq"
+
 type msgq struct {
-	conn     *kafka.Conn
-	kafkaUri string
-	topic    string
+	conn      *kafka.Conn
+	writer    *kafka.Writer
+	stats     *stats
+	kafkaUri  string
+	topic     string
+	partition int
+	tel       telemetry.Telemetry
 }
 
 type MQ interface {
@@ -19,6 +31,7 @@ type MQ interface {
 	KafkaUri() string
 	Topic() string
 	WriteMessage(ctx context.Context, msg interface{}) error
+	Close() error
 }
 
 func (m *msgq) createTopic() error {
@@ -33,13 +46,15 @@ func (m *msgq) createTopic() error {
 }
 
 func (m *msgq) CreateReader(groupId string) *kafka.Reader {
-	return kafka.NewReader(kafka.ReaderConfig{
+	r := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:  []string{m.kafkaUri},
 		Topic:    m.topic,
 		GroupID:  groupId,
 		MinBytes: 10e3,
 		MaxBytes: 10e6,
 	})
+	m.stats.addReader(r, groupId)
+	return r
 }
 
 func (m *msgq) Conn() *kafka.Conn {
@@ -59,33 +74,65 @@ func (m *msgq) WriteMessage(ctx context.Context, msg interface{}) error {
 	if err != nil {
 		return err
 	}
-	spanCtx, err := json.Marshal(ctx)
-	if err != nil {
-		return err
-	}
-	h := kafka.Header{Key: "span-context", Value: spanCtx}
-	kmsg := kafka.Message{Value: b, Headers: []kafka.Header{h}}
-	if _, err := m.conn.WriteMessages(kmsg); err != nil {
-		return err
-	}
-	if err := m.conn.Close(); err != nil {
+	t := m.tel.Tracer()(tracerName)
+	sctx, span := t.Start(
+		ctx,
+		fmt.Sprintf("%s send", m.topic),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(m.topic),
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingMessagePayloadSizeBytesKey.Int(len(b)),
+			semconv.MessagingKafkaPartitionKey.Int(m.partition),
+			semconv.NetPeerNameKey.String(m.kafkaUri),
+		),
+	)
+	defer span.End()
+
+	kmsg := kafka.Message{Value: b}
+	m.tel.Propagator().Inject(sctx, NewHeaderCarrier(&kmsg))
+	if err := m.writer.WriteMessages(sctx, kmsg); err != nil {
+		telemetry.RecordError(span, err, true)
 		return err
 	}
 	return nil
 }
 
-func NewMq(uri string, topic string) (res MQ, err error) {
-	conn, err := kafka.DialLeader(context.Background(), "tcp", uri, topic, 0)
+func (m *msgq) Close() error {
+	m.stats.stop()
+	return multierr.Combine(m.writer.Close(), m.conn.Close())
+}
+
+func NewMq(uri string, topic string, statsInterval time.Duration, tel telemetry.Telemetry) (res MQ, err error) {
+	partition := 0
+	conn, err := kafka.DialLeader(context.Background(), "tcp", uri, topic, partition)
 	if err != nil {
 		return nil, err
 	}
+	// all messages go to the partition the connection was dialed for
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(uri),
+		Topic:        topic,
+		Balancer:     kafka.BalancerFunc(func(kafka.Message, ...int) int { return partition }),
+		BatchTimeout: 10 * time.Millisecond,
+	}
 	msgQ := msgq{
-		conn,
-		uri,
-		topic,
+		conn:      conn,
+		writer:    w,
+		stats:     newStats(uri, topic, w, statsInterval),
+		kafkaUri:  uri,
+		topic:     topic,
+		partition: partition,
+		tel:       tel,
 	}
 	if err := msgQ.createTopic(); err != nil {
-		return nil, err
+		return nil, multierr.Append(err, msgQ.Close())
+	}
+	if err := msgQ.stats.register(tel.Meter()); err != nil {
+		// Close also silences the observers registered before the failure
+		return nil, multierr.Append(err, msgQ.Close())
 	}
+	msgQ.stats.run()
 	return &msgQ, nil
 }
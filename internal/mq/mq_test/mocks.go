@@ -1,6 +1,8 @@
 package mq_test
 
 import (
+	"context"
+
 	"github.com/morzhanov/go-otel/internal/mq"
 	"github.com/segmentio/kafka-go"
 )
@@ -25,9 +27,12 @@ func (m *MqMock) KafkaUri() string {
 func (m *MqMock) Topic() string {
 	return m.topicMock()
 }
-func (m *MqMock) WriteMessage(_ interface{}) error {
+func (m *MqMock) WriteMessage(_ context.Context, _ interface{}) error {
 	return m.writeMock()
 }
+func (m *MqMock) Close() error {
+	return nil
+}
 
 func NewMqMock(
 	createReader func(groupId string) *kafka.Reader,
@@ -0,0 +1,275 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.uber.org/multierr"
+)
+
+// kafka-go resets its counters on every Stats call, so the stats are polled
+// from a single goroutine and accumulated here for the observers.
+const defaultStatsInterval = 10 * time.Second
+
+// StatsInterval parses KafkaStatsInterval.
+func StatsInterval(c *config.Config) (time.Duration, error) {
+	if c.KafkaStatsInterval == "" {
+		return defaultStatsInterval, nil
+	}
+	d, err := time.ParseDuration(c.KafkaStatsInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid kafka stats interval %q", c.KafkaStatsInterval)
+	}
+	return d, nil
+}
+
+type totals struct {
+	messages   int64
+	bytes      int64
+	errors     int64
+	rebalances int64
+}
+
+type latency struct {
+	avg time.Duration
+	max time.Duration
+}
+
+type readerStats struct {
+	reader *kafka.Reader
+	group  string
+	totals
+	fetch latency
+	lag   map[int]int64
+}
+
+type stats struct {
+	mu       sync.Mutex
+	interval time.Duration
+	stopped  bool
+	client   *kafka.Client
+	topic    string
+	writer   *kafka.Writer
+	producer totals
+	write    latency
+	readers  []*readerStats
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newStats(uri string, topic string, w *kafka.Writer, interval time.Duration) *stats {
+	return &stats{
+		interval: interval,
+		client:   &kafka.Client{Addr: kafka.TCP(uri), Timeout: interval / 2},
+		topic:    topic,
+		writer:   w,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *stats) addReader(r *kafka.Reader, group string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readers = append(s.readers, &readerStats{reader: r, group: group})
+}
+
+// lag returns the difference between the last offset and the offset
+// committed by the group for every partition of the topic.
+func (s *stats) lag(ctx context.Context, group string) (map[int]int64, error) {
+	md, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{s.topic}})
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int
+	for _, t := range md.Topics {
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	committed, err := s.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: group,
+		Topics:  map[string][]int{s.topic: partitions},
+	})
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]kafka.OffsetRequest, len(partitions))
+	for i, p := range partitions {
+		reqs[i] = kafka.LastOffsetOf(p)
+	}
+	last, err := s.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{s.topic: reqs}})
+	if err != nil {
+		return nil, err
+	}
+	offsets := map[int]int64{}
+	for _, p := range committed.Topics[s.topic] {
+		if p.Error == nil {
+			offsets[p.Partition] = p.CommittedOffset
+		}
+	}
+	lag := make(map[int]int64, len(partitions))
+	for _, p := range last.Topics[s.topic] {
+		if p.Error != nil {
+			continue
+		}
+		c, ok := offsets[p.Partition]
+		if !ok || c < 0 {
+			c = p.FirstOffset
+		}
+		lag[p.Partition] = p.LastOffset - c
+	}
+	return lag, nil
+}
+
+func (s *stats) collect(ctx context.Context) {
+	ws := s.writer.Stats()
+	s.mu.Lock()
+	s.producer.messages += ws.Messages
+	s.producer.bytes += ws.Bytes
+	s.producer.errors += ws.Errors
+	s.write = latency{avg: ws.WriteTime.Avg, max: ws.WriteTime.Max}
+	readers := append([]*readerStats(nil), s.readers...)
+	s.mu.Unlock()
+
+	for _, r := range readers {
+		rs := r.reader.Stats()
+		lag, err := s.lag(ctx, r.group)
+		s.mu.Lock()
+		r.messages += rs.Messages
+		r.bytes += rs.Bytes
+		r.errors += rs.Errors
+		r.rebalances += rs.Rebalances
+		r.fetch = latency{avg: rs.ReadTime.Avg, max: rs.ReadTime.Max}
+		if err == nil {
+			r.lag = lag
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *stats) run() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(s.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+				s.collect(ctx)
+				cancel()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the polling, the observers stay registered with the meter but
+// report nothing for stopped stats. It can be called more than once and
+// before run.
+func (s *stats) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+}
+
+func (s *stats) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("kafka"),
+		semconv.MessagingDestinationKey.String(s.topic),
+	}
+}
+
+func observeLatency(observe func(float64, ...attribute.KeyValue), l latency, attrs []attribute.KeyValue) {
+	ms := float64(time.Millisecond)
+	observe(float64(l.avg)/ms, append(attrs, attribute.String("stat", "avg"))...)
+	observe(float64(l.max)/ms, append(attrs[:len(attrs):len(attrs)], attribute.String("stat", "max"))...)
+}
+
+// producerObserver reads a value of the accumulated writer stats.
+func (s *stats) producerObserver(value func() float64) meter.GaugeFunc {
+	return func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.stopped {
+			observe(value(), s.attributes()...)
+		}
+	}
+}
+
+// readerObserver reads a value of the accumulated stats of every reader.
+func (s *stats) readerObserver(value func(r *readerStats) float64) meter.GaugeFunc {
+	return func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.stopped {
+			return
+		}
+		for _, r := range s.readers {
+			observe(value(r), append(s.attributes(), semconv.MessagingKafkaConsumerGroupKey.String(r.group))...)
+		}
+	}
+}
+
+// register adds the observers to the meter, every MQ of the process shares
+// the instruments and reports its own topic.
+func (s *stats) register(m meter.Meter) (err error) {
+	counters := map[string]meter.GaugeFunc{
+		meter.KafkaProducerMessages:   s.producerObserver(func() float64 { return float64(s.producer.messages) }),
+		meter.KafkaProducerBytes:      s.producerObserver(func() float64 { return float64(s.producer.bytes) }),
+		meter.KafkaProducerErrors:     s.producerObserver(func() float64 { return float64(s.producer.errors) }),
+		meter.KafkaConsumerMessages:   s.readerObserver(func(r *readerStats) float64 { return float64(r.messages) }),
+		meter.KafkaConsumerBytes:      s.readerObserver(func(r *readerStats) float64 { return float64(r.bytes) }),
+		meter.KafkaConsumerErrors:     s.readerObserver(func(r *readerStats) float64 { return float64(r.errors) }),
+		meter.KafkaConsumerRebalances: s.readerObserver(func(r *readerStats) float64 { return float64(r.rebalances) }),
+	}
+	for name, fn := range counters {
+		err = multierr.Append(err, m.ObservableCounter(name, fn))
+	}
+	err = multierr.Append(err, m.Gauge(meter.KafkaProducerWriteTime, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.stopped {
+			observeLatency(observe, s.write, s.attributes())
+		}
+	}))
+	err = multierr.Append(err, m.Gauge(meter.KafkaConsumerFetchTime, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.stopped {
+			return
+		}
+		for _, r := range s.readers {
+			observeLatency(observe, r.fetch, append(s.attributes(), semconv.MessagingKafkaConsumerGroupKey.String(r.group)))
+		}
+	}))
+	err = multierr.Append(err, m.Gauge(meter.KafkaConsumerLag, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.stopped {
+			return
+		}
+		for _, r := range s.readers {
+			for p, lag := range r.lag {
+				observe(float64(lag), append(
+					s.attributes(),
+					semconv.MessagingKafkaConsumerGroupKey.String(r.group),
+					semconv.MessagingKafkaPartitionKey.Int(p),
+				)...)
+			}
+		}
+	}))
+	return err
+}
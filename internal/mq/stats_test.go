@@ -0,0 +1,30 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestStatsStop(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		running bool
+	}{
+		{name: "before run"},
+		{name: "after run", running: true},
+	} {
+		s := newStats("127.0.0.1:1", "orders", &kafka.Writer{}, time.Hour)
+		if tc.running {
+			s.run()
+		}
+		// Close can reach stop twice, e.g. when NewMq fails and the caller
+		// closes the controller as well
+		s.stop()
+		s.stop()
+		if !s.stopped {
+			t.Errorf("%s: stats not stopped", tc.name)
+		}
+	}
+}
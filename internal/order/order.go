@@ -2,6 +2,8 @@ package order
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
@@ -17,6 +19,8 @@ import (
 	"go.uber.org/zap"
 )
 
+const tracerName = "github.com/morzhanov/go-otel/internal/order"
+
 type service struct {
 	rest.BaseController
 	coll *mongo.Collection
@@ -27,98 +31,73 @@ type Service interface {
 	Listen()
 }
 
-func (s *service) handleHttpErr(ctx *gin.Context, err error) {
-	ctx.String(http.StatusInternalServerError, err.Error())
-	s.BaseController.Logger().Info("error in the REST handler", zap.Error(err))
-}
-
 func (s *service) handleCreateOrder(ctx *gin.Context) {
-	s.Meter().IncReqCount()
-	t := s.Tracer()("rest")
-	dbt := s.Tracer()("mongodb")
-	parentCtx, err := rest.GetSpanContext(ctx)
-	if err != nil {
-		s.handleHttpErr(ctx, err)
-		return
-	}
-	_, span := t.Start(*parentCtx, "create-order")
+	sctx, span := s.Tracer()(tracerName).Start(rest.GetSpanContext(ctx), "create-order")
 	defer span.End()
-	dbctx, dbspan := dbt.Start(*parentCtx, "create-order")
-	defer dbspan.End()
 
 	jsonData, err := ioutil.ReadAll(ctx.Request.Body)
 	if err != nil {
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 	d := porder.CreateOrderMessage{}
 	if err = json.Unmarshal(jsonData, &d); err != nil {
-		s.handleHttpErr(ctx, err)
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 
 	id := uuid.NewV4().String()
 	msg := porder.OrderMessage{Id: id, Name: d.Name, Amount: d.Amount, Status: "new"}
-	_, err = s.coll.InsertOne(dbctx, &msg)
+	_, err = s.coll.InsertOne(sctx, &msg)
 	if err != nil {
-		s.handleHttpErr(ctx, err)
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, &msg)
 }
 
 func (s *service) handleProcessOrder(ctx *gin.Context) {
-	s.Meter().IncReqCount()
-	t := s.Tracer()("rest")
-	dbt := s.Tracer()("mongodb")
-	et := s.Tracer()("kafka")
-	parentCtx, err := rest.GetSpanContext(ctx)
-	if err != nil {
-		s.handleHttpErr(ctx, err)
-		return
-	}
-	_, span := t.Start(*parentCtx, "process-order")
+	sctx, span := s.Tracer()(tracerName).Start(rest.GetSpanContext(ctx), "process-order")
 	defer span.End()
-	dbctx, dbspan := dbt.Start(*parentCtx, "process-order")
-	defer dbspan.End()
-	dbctxInsert, dbspanInsert := dbt.Start(*parentCtx, "get-order")
-	defer dbspanInsert.End()
-	ectx, espan := et.Start(*parentCtx, "process-order")
-	defer espan.End()
 
 	id := ctx.Param("id")
-	filter := bson.D{{"_id", id}}
-	update := bson.D{{"$set", bson.D{{"status", "processed"}}}}
-	_, err = s.coll.UpdateOne(dbctx, filter, update)
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "processed"}}}}
+	_, err := s.coll.UpdateOne(sctx, filter, update)
 	if err != nil {
-		s.handleHttpErr(ctx, err)
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
-	res := s.coll.FindOne(dbctxInsert, filter)
-	if res.Err() != nil {
-		s.handleHttpErr(ctx, res.Err())
+	res := s.coll.FindOne(sctx, filter)
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = telemetry.NotFoundError(fmt.Errorf("order %s not found: %w", id, err))
+		}
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 	msg := porder.OrderMessage{}
 	if err := res.Decode(&msg); err != nil {
-		s.handleHttpErr(ctx, res.Err())
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 
-	if err := s.mq.WriteMessage(ectx, &payment.ProcessPaymentMessage{OrderId: msg.Id, Name: msg.Name, Amount: msg.Amount, Status: msg.Status}); err != nil {
-		s.handleHttpErr(ctx, res.Err())
+	if err := s.mq.WriteMessage(sctx, &payment.ProcessPaymentMessage{OrderId: msg.Id, Name: msg.Name, Amount: msg.Amount, Status: msg.Status}); err != nil {
+		s.HandleRestError(ctx, sctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, &msg)
 }
 
 func (s *service) Listen() {
-	r := s.BaseController.Router()
-	r.POST("/", s.handleCreateOrder)
-	r.POST("/:id", s.handleProcessOrder)
-	r.Run()
+	s.BaseController.Router().Run()
 }
 
 func NewService(log *zap.Logger, tel telemetry.Telemetry, coll *mongo.Collection, msgq mq.MQ) Service {
 	bc := rest.NewBaseController(log, tel)
-	return &service{BaseController: bc, coll: coll, mq: msgq}
+	s := &service{BaseController: bc, coll: coll, mq: msgq}
+	r := bc.Router()
+	r.POST("/", s.handleCreateOrder)
+	r.PUT("/:id", s.handleProcessOrder)
+	return s
 }
@@ -0,0 +1,87 @@
+package order
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/morzhanov/go-otel/internal/mongodb"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.uber.org/zap"
+)
+
+// unreachableMongo fails every command quickly, nothing listens on port 1.
+const unreachableMongo = "mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100&connectTimeoutMS=100"
+
+func serve(t *testing.T, method, target, body string) (*telemetrytest.Telemetry, *httptest.ResponseRecorder) {
+	t.Helper()
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll, err := mongodb.NewMongoDB(unreachableMongo, tel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tel, serveWith(tel, coll, method, target, body)
+}
+
+func serveWith(tel *telemetrytest.Telemetry, coll *mongo.Collection, method, target, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	s := NewService(zap.NewNop(), tel, coll, nil).(*service)
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestCreateOrderRejectsBadBody(t *testing.T) {
+	tel, w := serve(t, http.MethodPost, "/", `{"name":`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	tel.AssertParent(t, "create-order", "POST /")
+	tel.AssertStatus(t, "create-order", codes.Unset)
+	if events := tel.Span(t, "create-order").Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("create-order events %v, want one exception", events)
+	}
+}
+
+func TestProcessOrderFails(t *testing.T) {
+	tel, w := serve(t, http.MethodPut, "/42", "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	tel.AssertParent(t, "process-order", "PUT /:id")
+	tel.AssertAttribute(t, "PUT /:id", semconv.HTTPStatusCodeKey.Int(http.StatusInternalServerError))
+	tel.AssertStatus(t, "process-order", codes.Error)
+	tel.AssertStatus(t, "PUT /:id", codes.Error)
+}
+
+func TestProcessOrderNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("missing order", func(mt *mtest.T) {
+		tel, err := telemetrytest.New(nil)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.orders", mtest.FirstBatch),
+		)
+		w := serveWith(tel, mt.Coll, http.MethodPut, "/42", "")
+		if w.Code != http.StatusNotFound {
+			mt.Fatalf("status %d, want %d: %s", w.Code, http.StatusNotFound, w.Body)
+		}
+		tel.AssertAttribute(mt.T, "PUT /:id", semconv.HTTPStatusCodeKey.Int(http.StatusNotFound))
+		tel.AssertStatus(mt.T, "process-order", codes.Unset)
+		tel.AssertStatus(mt.T, "PUT /:id", codes.Unset)
+	})
+}
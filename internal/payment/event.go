@@ -6,9 +6,10 @@ import (
 
 	"github.com/morzhanov/go-otel/internal/telemetry"
 
-	gpayment "github.com/morzhanov/go-otel/api/grpc/payment"
+	gpayment "github.com/morzhanov/go-otel/api/payment"
 	"github.com/morzhanov/go-otel/internal/config"
 	"github.com/morzhanov/go-otel/internal/event"
+	"github.com/morzhanov/go-otel/internal/mq"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
 )
@@ -20,24 +21,17 @@ type eventController struct {
 
 type Controller interface {
 	Listen(ctx context.Context)
+	Close() error
 }
 
-func (c *eventController) processPayment(in *kafka.Message) {
-	c.Meter().IncReqCount()
-	et := c.Tracer()("kafka")
-	pctx, err := event.GetSpanContext(in)
-	if err != nil {
-		c.Logger().Error("error during process payment event processing", zap.Error(err))
-	}
-	sctx, span := et.Start(*pctx, "process-payment")
-	defer span.End()
-
+func (c *eventController) processPayment(sctx context.Context, in *kafka.Message) {
 	res := gpayment.ProcessPaymentMessage{}
 	if err := json.Unmarshal(in.Value, &res); err != nil {
-		c.Logger().Error("error during process payment event processing", zap.Error(err))
+		c.HandleError(sctx, err)
+		return
 	}
 	if err := c.pay.ProcessPayment(sctx, &res); err != nil {
-		c.Logger().Error("error during process payment event processing", zap.Error(err))
+		c.HandleError(sctx, err)
 	}
 }
 
@@ -51,6 +45,10 @@ func NewController(
 	log *zap.Logger,
 	tel telemetry.Telemetry,
 ) (Controller, error) {
-	controller, err := event.NewController(c.KafkaURL, c.KafkaTopic, c.KafkaGroupID, log, tel)
+	interval, err := mq.StatsInterval(c)
+	if err != nil {
+		return nil, err
+	}
+	controller, err := event.NewController(c.KafkaURL, c.KafkaTopic, c.KafkaGroupID, interval, log, tel)
 	return &eventController{BaseController: controller, pay: pay}, err
 }
@@ -2,11 +2,14 @@ package payment
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/morzhanov/go-otel/internal/telemetry"
 
 	"github.com/jmoiron/sqlx"
-	gpayment "github.com/morzhanov/go-otel/api/grpc/payment"
+	gpayment "github.com/morzhanov/go-otel/api/payment"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -21,32 +24,24 @@ type Payment interface {
 }
 
 func (p *pay) GetPaymentInfo(ctx context.Context, in *gpayment.GetPaymentInfoRequest) (*gpayment.PaymentMessage, error) {
-	pt := p.tel.Tracer()("postgres")
-	dbctx, dbspan := pt.Start(ctx, "process-payment")
-	defer dbspan.End()
-
 	var (
 		id, orderID, name, status string
 		amount                    int32
 	)
-	rows, err := p.db.QueryContext(dbctx, `SELECT * FROM payments WHERE order_id = $id`, in.OrderId)
-	if err != nil {
-		return nil, err
-	}
-	if err := rows.Scan(&id, &orderID, &name, &amount, &status); err != nil {
+	row := p.db.QueryRowContext(ctx, `SELECT * FROM payments WHERE order_id = $id`, in.OrderId)
+	if err := row.Scan(&id, &orderID, &name, &amount, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = telemetry.NotFoundError(fmt.Errorf("payment of order %s not found: %w", in.OrderId, err))
+		}
 		return nil, err
 	}
 	return &gpayment.PaymentMessage{Id: id, OrderId: orderID, Name: name, Status: status, Amount: amount}, nil
 }
 
 func (p *pay) ProcessPayment(ctx context.Context, in *gpayment.ProcessPaymentMessage) error {
-	pt := p.tel.Tracer()("postgres")
-	dbctx, dbspan := pt.Start(ctx, "process-payment")
-	defer dbspan.End()
-
 	id := uuid.NewV4().String()
-	if _, err := p.db.QueryContext(
-		dbctx,
+	if _, err := p.db.ExecContext(
+		ctx,
 		`INSERT INTO payments (id, order_id, name, amount, status) VALUES ($id, $orderId, $name, $amount, $status)`,
 		id, in.OrderId, in.Name, in.Amount, in.Status,
 	); err != nil {
@@ -0,0 +1,122 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	gpayment "github.com/morzhanov/go-otel/api/payment"
+	gserver "github.com/morzhanov/go-otel/internal/grpc"
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const getPaymentInfo = "payment.Payment/GetPaymentInfo"
+
+type fakePayment struct {
+	err error
+}
+
+func (p fakePayment) GetPaymentInfo(ctx context.Context, in *gpayment.GetPaymentInfoRequest) (*gpayment.PaymentMessage, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &gpayment.PaymentMessage{Id: "1", OrderId: in.OrderId, Status: "processed"}, nil
+}
+
+func (p fakePayment) ProcessPayment(ctx context.Context, in *gpayment.ProcessPaymentMessage) error {
+	return p.err
+}
+
+// call runs GetPaymentInfo through a client and a server with their own
+// telemetry, like apigw and payment.
+func call(t *testing.T, pay Payment) (client, srv *telemetrytest.Telemetry, err error) {
+	t.Helper()
+	if client, err = telemetrytest.New(nil); err != nil {
+		t.Fatal(err)
+	}
+	if srv, err = telemetrytest.New(nil); err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer("", "", zap.NewNop(), pay, srv).(*server)
+	go func() { _ = s.srv.Serve(lis) }()
+	defer s.srv.Stop()
+
+	conn, err := gserver.Dial(
+		"bufnet",
+		client,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, span := client.Tracer()("test").Start(context.Background(), "get-payment-info")
+	_, err = gpayment.NewPaymentClient(conn).GetPaymentInfo(ctx, &gpayment.GetPaymentInfoRequest{OrderId: "42"})
+	span.End()
+	return client, srv, err
+}
+
+func TestGetPaymentInfo(t *testing.T) {
+	client, server, err := call(t, fakePayment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AssertKind(t, getPaymentInfo, trace.SpanKindClient)
+	client.AssertParent(t, getPaymentInfo, "get-payment-info")
+	server.AssertKind(t, getPaymentInfo, trace.SpanKindServer)
+	server.AssertAttribute(t, getPaymentInfo, semconv.RPCMethodKey.String("GetPaymentInfo"))
+
+	cs, ss := client.Span(t, getPaymentInfo), server.Span(t, getPaymentInfo)
+	if ss.Parent().SpanID() != cs.SpanContext().SpanID() || ss.SpanContext().TraceID() != cs.SpanContext().TraceID() {
+		t.Errorf("server span parent %s, want the client span %s", ss.Parent().SpanID(), cs.SpanContext().SpanID())
+	}
+	server.Collect(t)
+	if n := server.HistogramCount(t, meter.RPCServerDuration, semconv.RPCMethodKey.String("GetPaymentInfo")); n != 1 {
+		t.Errorf("%s count = %d, want 1", meter.RPCServerDuration, n)
+	}
+}
+
+func TestGetPaymentInfoFails(t *testing.T) {
+	client, server, err := call(t, fakePayment{err: errors.New("connection refused")})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	client.AssertStatus(t, getPaymentInfo, codes.Error)
+	server.AssertStatus(t, getPaymentInfo, codes.Error)
+
+	var de interface{ DownstreamTraceID() string }
+	if !errors.As(err, &de) {
+		t.Fatalf("error %v doesn't carry the downstream trace ID", err)
+	}
+	if want := server.Span(t, getPaymentInfo).SpanContext().TraceID().String(); de.DownstreamTraceID() != want {
+		t.Errorf("DownstreamTraceID() = %q, want %q", de.DownstreamTraceID(), want)
+	}
+	if got := status.Code(err); got != grpccodes.Unknown {
+		t.Errorf("status code = %v, want %v", got, grpccodes.Unknown)
+	}
+}
+
+func TestGetPaymentInfoNotFound(t *testing.T) {
+	client, server, err := call(t, fakePayment{err: telemetry.NotFoundError(errors.New("payment of order 42 not found"))})
+	if got := status.Code(err); got != grpccodes.NotFound {
+		t.Fatalf("status code = %v, want %v", got, grpccodes.NotFound)
+	}
+	// a missing payment is the caller's problem, the server didn't fail
+	server.AssertStatus(t, getPaymentInfo, codes.Unset)
+	server.AssertAttribute(t, getPaymentInfo, semconv.RPCGRPCStatusCodeKey.Int(int(grpccodes.NotFound)))
+	client.AssertStatus(t, getPaymentInfo, codes.Error)
+}
@@ -0,0 +1,341 @@
+package psql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/morzhanov/go-otel/internal/psql"
+
+type tracer struct {
+	tracer   trace.Tracer
+	duration meter.Histogram
+}
+
+// start only creates spans below an existing one, so pool maintenance and
+// migrations run with a background context don't produce root traces.
+func (t *tracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return t.tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// startQuery starts the span of a query or exec, the returned func ends it
+// and records db.client.duration.
+func (t *tracer) startQuery(ctx context.Context, query string) (context.Context, func(err error)) {
+	ctx, span := t.start(ctx, spanName(query), SpanAttributes(query)...)
+	start := time.Now()
+	return ctx, func(err error) {
+		end(span, err)
+		if err == driver.ErrSkip {
+			return
+		}
+		t.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), queryAttributes(query)...)
+	}
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		telemetry.RecordError(span, err, true)
+	}
+	span.End()
+}
+
+func namedValuesToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return values
+}
+
+type connector struct {
+	driver.Connector
+	t *tracer
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	ctx, span := c.t.start(ctx, "connect")
+	cn, err := c.Connector.Connect(ctx)
+	end(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, t: c.t}, nil
+}
+
+type conn struct {
+	driver.Conn
+	t *tracer
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	p, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	ctx, span := c.t.start(ctx, "ping")
+	err := p.Ping(ctx)
+	end(span, err)
+	return err
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	ctx, span := c.t.start(ctx, "prepare", SpanAttributes(query)...)
+	var (
+		st  driver.Stmt
+		err error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = p.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	end(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: st, query: query, t: c.t}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	qctx, done := c.t.startQuery(ctx, query)
+	r, err := q.QueryContext(qctx, query, args)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, r, c.t), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, done := c.t.startQuery(ctx, query)
+	res, err := e.ExecContext(ctx, query, args)
+	done(err)
+	return res, err
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	bctx, span := c.t.start(ctx, "begin")
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(bctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	end(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx, ctx: ctx, t: c.t}, nil
+}
+
+type txn struct {
+	driver.Tx
+	ctx context.Context
+	t   *tracer
+}
+
+func (tx *txn) Commit() error {
+	_, span := tx.t.start(tx.ctx, "commit")
+	err := tx.Tx.Commit()
+	end(span, err)
+	return err
+}
+
+func (tx *txn) Rollback() error {
+	_, span := tx.t.start(tx.ctx, "rollback")
+	err := tx.Tx.Rollback()
+	end(span, err)
+	return err
+}
+
+type stmt struct {
+	driver.Stmt
+	query string
+	t     *tracer
+}
+
+func (s *stmt) CheckNamedValue(v *driver.NamedValue) error {
+	if nvc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, done := s.t.startQuery(ctx, s.query)
+	var (
+		res driver.Result
+		err error
+	)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else if err = ctx.Err(); err == nil {
+		res, err = s.Stmt.Exec(namedValuesToValues(args))
+	}
+	done(err)
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	qctx, done := s.t.startQuery(ctx, s.query)
+	var (
+		r   driver.Rows
+		err error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		r, err = q.QueryContext(qctx, args)
+	} else if err = qctx.Err(); err == nil {
+		r, err = s.Stmt.Query(namedValuesToValues(args))
+	}
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, r, s.t), nil
+}
+
+// rows traces the iteration over the result set, the span ends on Close.
+type rows struct {
+	driver.Rows
+	span  trace.Span
+	count int
+	err   error
+}
+
+func newRows(ctx context.Context, r driver.Rows, t *tracer) *rows {
+	_, span := t.start(ctx, "rows")
+	return &rows{Rows: r, span: span}
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+func (r *rows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *rows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+	return io.EOF
+}
+
+// The column type methods are forwarded so sql.Rows.ColumnTypes keeps
+// working, the fallbacks are what database/sql assumes for drivers without
+// them.
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if c, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return c.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if c, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return c.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *rows) ColumnTypeLength(index int) (int64, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return c.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return c.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return c.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r *rows) Close() error {
+	err := r.Rows.Close()
+	r.span.SetAttributes(attribute.Int("db.rows", r.count))
+	if r.err == nil {
+		r.err = err
+	}
+	end(r.span, r.err)
+	return err
+}
+
+// NewConnector wraps the driver connector so that connections, queries,
+// statements, transactions and rows are traced.
+func NewConnector(c driver.Connector, tel telemetry.Telemetry) driver.Connector {
+	return &connector{Connector: c, t: &tracer{
+		tracer:   tel.Tracer()(tracerName),
+		duration: tel.Meter().Histogram(meter.DBClientDuration),
+	}}
+}
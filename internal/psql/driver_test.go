@@ -0,0 +1,94 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"github.com/morzhanov/go-otel/internal/telemetry/telemetrytest"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{left: 2}, nil
+}
+
+type fakeRows struct {
+	left int
+}
+
+func (*fakeRows) Columns() []string { return []string{"amount"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(r.left)
+	return nil
+}
+
+func (*fakeRows) ColumnTypeScanType(int) reflect.Type               { return reflect.TypeOf(int64(0)) }
+func (*fakeRows) ColumnTypeDatabaseTypeName(int) string             { return "INT8" }
+func (*fakeRows) ColumnTypeNullable(int) (bool, bool)               { return true, true }
+func (*fakeRows) ColumnTypeLength(int) (int64, bool)                { return 0, false }
+func (*fakeRows) ColumnTypePrecisionScale(int) (int64, int64, bool) { return 0, 0, false }
+
+func TestQuery(t *testing.T) {
+	tel, err := telemetrytest.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(NewConnector(fakeConnector{}, tel))
+	defer db.Close()
+
+	ctx, span := tel.Tracer()("test").Start(context.Background(), "handler")
+	rs, err := db.QueryContext(ctx, "SELECT amount FROM payments WHERE id = $1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, err := rs.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := types[0].DatabaseTypeName(); got != "INT8" {
+		t.Errorf("DatabaseTypeName() = %q, want INT8", got)
+	}
+	if got := types[0].ScanType(); got != reflect.TypeOf(int64(0)) {
+		t.Errorf("ScanType() = %v, want int64", got)
+	}
+	if nullable, ok := types[0].Nullable(); !nullable || !ok {
+		t.Errorf("Nullable() = %t, %t, want true, true", nullable, ok)
+	}
+	for rs.Next() {
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	tel.AssertParent(t, "SELECT payments", "handler")
+	tel.AssertAttribute(t, "SELECT payments", semconv.DBStatementKey.String("SELECT amount FROM payments WHERE id = $1"))
+	tel.AssertAttribute(t, "rows", attribute.Int("db.rows", 2))
+	tel.Collect(t)
+	if n := tel.HistogramCount(t, meter.DBClientDuration, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("payments")); n != 1 {
+		t.Errorf("%s count = %d, want 1", meter.DBClientDuration, n)
+	}
+}
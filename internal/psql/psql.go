@@ -1,6 +1,7 @@
 package psql
 
 import (
+	"database/sql"
 	"fmt"
 	"path/filepath"
 
@@ -8,10 +9,25 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/morzhanov/go-otel/internal/telemetry"
 )
 
-func NewDb(uri string) (*sqlx.DB, error) {
-	return sqlx.Connect("postgres", uri)
+func NewDb(uri string, tel telemetry.Telemetry) (*sqlx.DB, error) {
+	c, err := pq.NewConnector(uri)
+	if err != nil {
+		return nil, err
+	}
+	db := sqlx.NewDb(sql.OpenDB(NewConnector(c, tel)), "postgres")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := registerStats(db.DB, tel.Meter()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 func RunMigrations(db *sqlx.DB) error {
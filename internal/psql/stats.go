@@ -0,0 +1,32 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.uber.org/multierr"
+)
+
+// registerStats exports sql.DBStats of the pool, connections by state like
+// the Mongo pool monitor does.
+func registerStats(db *sql.DB, m meter.Meter) (err error) {
+	system := semconv.DBSystemPostgreSQL
+	err = m.Gauge(meter.DBClientConnectionsUsage, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s := db.Stats()
+		observe(float64(s.InUse), system, attribute.String("state", "used"))
+		observe(float64(s.Idle), system, attribute.String("state", "idle"))
+	})
+	err = multierr.Append(err, m.Gauge(meter.DBClientConnectionsMax, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		observe(float64(db.Stats().MaxOpenConnections), system)
+	}))
+	err = multierr.Append(err, m.ObservableCounter(meter.DBClientConnectionsWaitCount, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		observe(float64(db.Stats().WaitCount), system)
+	}))
+	return multierr.Append(err, m.ObservableCounter(meter.DBClientConnectionsWaitTime, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		observe(float64(db.Stats().WaitDuration)/float64(time.Millisecond), system)
+	}))
+}
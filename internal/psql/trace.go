@@ -0,0 +1,58 @@
+package psql
+
+import (
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+var (
+	literalRe = regexp.MustCompile(`\$\w+|'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+	tableRe   = regexp.MustCompile(`(?i)\b(?:from|into|update|join)\s+("?[\w.]+"?)`)
+)
+
+// RedactSQL replaces string and numeric literals with "?", bind parameters
+// are kept as is.
+func RedactSQL(query string) string {
+	return literalRe.ReplaceAllStringFunc(query, func(m string) string {
+		if strings.HasPrefix(m, "$") {
+			return m
+		}
+		return "?"
+	})
+}
+
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// queryAttributes are the low cardinality attributes of the query which are
+// also used for db.client.duration.
+func queryAttributes(query string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationKey.String(operation(query)),
+	}
+	if m := tableRe.FindStringSubmatch(query); m != nil {
+		attrs = append(attrs, semconv.DBSQLTableKey.String(strings.Trim(m[1], `"`)))
+	}
+	return attrs
+}
+
+func SpanAttributes(query string) []attribute.KeyValue {
+	return append(queryAttributes(query), semconv.DBStatementKey.String(RedactSQL(query)))
+}
+
+func spanName(query string) string {
+	name := operation(query)
+	if m := tableRe.FindStringSubmatch(query); m != nil {
+		name += " " + strings.Trim(m[1], `"`)
+	}
+	return name
+}
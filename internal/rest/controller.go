@@ -3,15 +3,19 @@ package rest
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"time"
 
+	"github.com/morzhanov/go-otel/internal/logger"
 	"github.com/morzhanov/go-otel/internal/telemetry/meter"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/morzhanov/go-otel/internal/telemetry"
@@ -29,7 +33,7 @@ type baseController struct {
 type BaseController interface {
 	Listen(port string)
 	ParseRestBody(ctx *gin.Context, input interface{}) error
-	HandleRestError(ctx *gin.Context, err error)
+	HandleRestError(ctx *gin.Context, sctx context.Context, err error)
 	Handler(handler gin.HandlerFunc) gin.HandlerFunc
 	Router() *gin.Engine
 	Logger() *zap.Logger
@@ -69,13 +73,28 @@ func (c *baseController) ParseRestBody(ctx *gin.Context, input interface{}) erro
 	return json.Unmarshal(jsonData, &in)
 }
 
-func (c *baseController) HandleRestError(ctx *gin.Context, err error) {
-	c.log.Error(errors.Unwrap(err).Error())
-	if err.Error() == "not authorized" {
-		ctx.String(http.StatusUnauthorized, err.Error())
-		return
+// HandleRestError records err on the span in sctx, logs it and writes an
+// ErrorBody with the status derived from the error.
+func (c *baseController) HandleRestError(ctx *gin.Context, sctx context.Context, err error) {
+	if err == nil {
+		err = errMissing
 	}
-	ctx.String(http.StatusInternalServerError, err.Error())
+	code := StatusCode(err)
+	RecordError(sctx, code, err)
+	log := logger.WithContext(sctx, c.log)
+	if code >= http.StatusInternalServerError {
+		log.Error("error in the REST handler", zap.Error(err))
+	} else {
+		log.Info("request rejected", zap.Int("status", code), zap.Error(err))
+	}
+	body := ErrorBody{Error: err.Error(), Status: code, RequestID: RequestID(sctx)}
+	if sc := trace.SpanContextFromContext(sctx); sc.IsValid() {
+		body.TraceID = sc.TraceID().String()
+	}
+	if id := downstreamTraceID(err); id != body.TraceID {
+		body.DownstreamTraceID = id
+	}
+	ctx.JSON(code, body)
 }
 
 func (c *baseController) Handler(handler gin.HandlerFunc) gin.HandlerFunc {
@@ -83,32 +102,46 @@ func (c *baseController) Handler(handler gin.HandlerFunc) gin.HandlerFunc {
 }
 
 func PerformRequest(ctx context.Context, req *http.Request) ([]byte, error) {
-	sci := ctx.Value("span-context")
-	sc := sci.(trace.SpanContext)
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	ctx, span := tracer.Start(
+		ctx,
+		spanName(req.Method, ""),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	defer span.End()
+
 	req.Header.Set("content-type", "application/json")
-	spanCtx, err := sc.MarshalJSON()
-	if err != nil {
-		return nil, err
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
 	}
-	req.Header.Set("span-context", string(spanCtx))
-	res, err := http.DefaultClient.Do(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
+		telemetry.RecordError(span, err, true)
 		return nil, err
 	}
+	defer res.Body.Close()
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(res.StatusCode))
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode >= http.StatusBadRequest {
+		re := &ResponseError{StatusCode: res.StatusCode, TraceID: telemetry.ParseTraceResponse(res.Header.Get(telemetry.TraceResponseHeader))}
+		if json.Unmarshal(body, &re.Body) != nil {
+			re.Body = ErrorBody{Error: strings.TrimSpace(string(body)), Status: res.StatusCode}
+		}
+		return nil, re
+	}
 	return body, err
 }
 
-func GetSpanContext(ctx *gin.Context) (*context.Context, error) {
-	scs := ctx.GetHeader("span-context")
-	var sctx context.Context
-	if err := json.Unmarshal([]byte(scs), &sctx); err != nil {
-		return nil, err
-	}
-	return &sctx, nil
+// GetSpanContext returns the request context holding the server span started
+// by the base controller.
+func GetSpanContext(ctx *gin.Context) context.Context {
+	return ctx.Request.Context()
 }
 
 func (c *baseController) Router() *gin.Engine       { return c.router }
@@ -118,5 +151,6 @@ func (c *baseController) Meter() meter.Meter        { return c.tel.Meter() }
 
 func NewBaseController(log *zap.Logger, tel telemetry.Telemetry) BaseController {
 	router := gin.Default()
+	router.Use(tracingMiddleware(tel), metricsMiddleware(tel.Meter()))
 	return &baseController{router: router, log: log, tel: tel}
 }
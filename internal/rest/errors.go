@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errMissing stands in for the nil error of a handler that failed without
+// saying why.
+var errMissing = errors.New(http.StatusText(http.StatusInternalServerError))
+
+// ErrorBody is the JSON body of error responses, the trace ID lets support
+// find the trace of a failed request.
+type ErrorBody struct {
+	Error     string `json:"error"`
+	Status    int    `json:"status"`
+	TraceID   string `json:"trace_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// DownstreamTraceID is set when the failing downstream service reported
+	// a trace other than ours, e.g. because it didn't continue it.
+	DownstreamTraceID string `json:"downstream_trace_id,omitempty"`
+}
+
+// ResponseError is returned by PerformRequest for 4xx and 5xx responses.
+type ResponseError struct {
+	StatusCode int
+	// TraceID is taken from the traceresponse header of the response.
+	TraceID string
+	Body    ErrorBody
+}
+
+func (e *ResponseError) Error() string {
+	if e.Body.Error != "" {
+		return e.Body.Error
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+func (e *ResponseError) DownstreamTraceID() string { return e.TraceID }
+
+// downstreamTraceID returns the trace ID reported by the downstream service
+// that caused err, errors of any transport carry it with a DownstreamTraceID
+// method.
+func downstreamTraceID(err error) string {
+	var de interface{ DownstreamTraceID() string }
+	if errors.As(err, &de) {
+		return de.DownstreamTraceID()
+	}
+	return ""
+}
+
+// StatusCode maps a handler error to the HTTP status returned to the caller.
+func StatusCode(err error) int {
+	var re *ResponseError
+	switch {
+	case err == nil:
+		return http.StatusInternalServerError
+	case errors.As(err, &re):
+		return re.StatusCode
+	case err.Error() == "not authorized":
+		return http.StatusUnauthorized
+	case telemetry.IsNotFound(err), grpcCode(err) == codes.NotFound:
+		return http.StatusNotFound
+	case telemetry.IsClientError(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// grpcCode returns the status code of a gRPC call error, OK for other errors.
+func grpcCode(err error) codes.Code {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus().Code()
+	}
+	return codes.OK
+}
+
+// RecordError records err on the span in ctx, only 5xx responses mark the
+// span as failed since 4xx are caused by the client. The status code itself
+// is set on the server span by the tracing middleware.
+func RecordError(ctx context.Context, code int, err error) {
+	telemetry.RecordError(trace.SpanFromContext(ctx), err, code >= http.StatusInternalServerError)
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusInternalServerError},
+		{errors.New("boom"), http.StatusInternalServerError},
+		{errors.New("not authorized"), http.StatusUnauthorized},
+		{telemetry.ClientError(errors.New("bad input")), http.StatusBadRequest},
+		{fmt.Errorf("order service: %w", &ResponseError{StatusCode: http.StatusNotFound}), http.StatusNotFound},
+		{telemetry.NotFoundError(errors.New("no order")), http.StatusNotFound},
+		{status.Error(codes.NotFound, "no payment"), http.StatusNotFound},
+		{status.Error(codes.Internal, "payment failed"), http.StatusInternalServerError},
+	} {
+		if got := StatusCode(tc.err); got != tc.want {
+			t.Errorf("StatusCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+type downstreamError struct{}
+
+func (downstreamError) Error() string             { return "payment failed" }
+func (downstreamError) DownstreamTraceID() string { return "downstream" }
+
+func TestDownstreamTraceID(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{errors.New("boom"), ""},
+		{fmt.Errorf("order service: %w", &ResponseError{StatusCode: http.StatusNotFound, TraceID: "order"}), "order"},
+		{fmt.Errorf("payment service: %w", downstreamError{}), "downstream"},
+	} {
+		if got := downstreamTraceID(tc.err); got != tc.want {
+			t.Errorf("downstreamTraceID(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
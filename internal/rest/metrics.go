@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const unmatchedRoute = "unmatched"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// routeAttributes uses the route template instead of the raw path and folds
+// unknown methods so that client input can't blow up label cardinality.
+func routeAttributes(ctx *gin.Context) []attribute.KeyValue {
+	method := ctx.Request.Method
+	if !knownMethods[method] {
+		method = "_OTHER"
+	}
+	route := ctx.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	return []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(method),
+		semconv.HTTPRouteKey.String(route),
+	}
+}
+
+func metricsMiddleware(m meter.Meter) gin.HandlerFunc {
+	requests := m.Counter(meter.HTTPServerRequests)
+	duration := m.Histogram(meter.HTTPServerDuration)
+	active := m.UpDownCounter(meter.HTTPServerActiveRequests)
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		attrs := routeAttributes(ctx)
+		active.Add(ctx.Request.Context(), 1, attrs...)
+		ctx.Next()
+		rctx := ctx.Request.Context()
+		active.Add(rctx, -1, attrs...)
+		attrs = append(attrs, semconv.HTTPStatusCodeKey.Int(ctx.Writer.Status()))
+		requests.Add(rctx, 1, attrs...)
+		duration.Record(rctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+	}
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/morzhanov/go-otel/internal/rest"
+
+	requestIDHeader = "X-Request-Id"
+	// maxRequestIDLength bounds the request ID taken from the wire.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request served with ctx.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID keeps a sane X-Request-Id from the caller and falls back to the
+// trace ID otherwise.
+func requestID(req *http.Request, sc trace.SpanContext) string {
+	id := strings.TrimSpace(req.Header.Get(requestIDHeader))
+	if id == "" || len(id) > maxRequestIDLength || strings.IndexFunc(id, func(r rune) bool { return r < 0x21 || r > 0x7e }) >= 0 {
+		if !sc.IsValid() {
+			return ""
+		}
+		return sc.TraceID().String()
+	}
+	return id
+}
+
+func spanName(method string, route string) string {
+	if route == "" {
+		return fmt.Sprintf("HTTP %s", method)
+	}
+	return fmt.Sprintf("%s %s", method, route)
+}
+
+// tracingMiddleware starts a server span for every request and stores it in
+// the request context, handlers get it with GetSpanContext. Every response
+// carries the traceresponse and X-Request-Id headers.
+func tracingMiddleware(tel telemetry.Telemetry) gin.HandlerFunc {
+	tracer := tel.Tracer()(tracerName)
+	return func(ctx *gin.Context) {
+		req := ctx.Request
+		pctx := tel.Propagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		pctx = tel.Baggage().FromHeaders(pctx, req.Header)
+		route := ctx.FullPath()
+		sctx, span := tracer.Start(
+			pctx,
+			spanName(req.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, req)...),
+			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", req)...),
+		)
+		defer span.End()
+		sc := span.SpanContext()
+		id := requestID(req, sc)
+		if sc.IsValid() {
+			ctx.Header(telemetry.TraceResponseHeader, telemetry.TraceResponse(sc))
+		}
+		if id != "" {
+			ctx.Header(requestIDHeader, id)
+		}
+		ctx.Request = req.WithContext(context.WithValue(sctx, requestIDKey{}, id))
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
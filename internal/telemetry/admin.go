@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type AdminServer struct {
+	mux *http.ServeMux
+	srv *http.Server
+	log *zap.Logger
+}
+
+func (a *AdminServer) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+func (a *AdminServer) Shutdown(ctx context.Context) error {
+	return a.srv.Shutdown(ctx)
+}
+
+func NewAdminServer(addr string, log *zap.Logger) (*AdminServer, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	a := &AdminServer{mux: mux, srv: &http.Server{Handler: mux}, log: log}
+	go func() {
+		if err := a.srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error("telemetry admin server failed", zap.Error(err))
+		}
+	}()
+	log.Info("telemetry admin server running", zap.String("addr", lis.Addr().String()))
+	return a, nil
+}
@@ -0,0 +1,158 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Baggage holds the baggage policy of the service: which entries are accepted
+// from upstream, which of them are copied onto spans and logs, and which
+// request headers are turned into baggage at the edge.
+type Baggage struct {
+	allowAll   bool
+	allowed    map[string]bool
+	attributes []string
+	headers    map[string]string
+}
+
+const (
+	// BaggageAllowAll in BaggageKeys accepts any entry.
+	BaggageAllowAll = "*"
+	// maxBaggageMembers and maxBaggageBytes cap the accepted baggage, entries
+	// beyond them are dropped.
+	maxBaggageMembers = 16
+	maxBaggageBytes   = 1024
+)
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// NewBaggage parses BaggageKeys (allow-list, empty accepts no entry and "*"
+// any entry), BaggageAttributes and BaggageHeaders
+// ("X-Tenant-Id=tenant.id,...").
+func NewBaggage(c *config.Config) (*Baggage, error) {
+	b := &Baggage{attributes: splitList(c.BaggageAttributes), headers: map[string]string{}, allowed: map[string]bool{}}
+	for _, k := range splitList(c.BaggageKeys) {
+		if k == BaggageAllowAll {
+			b.allowAll = true
+			continue
+		}
+		b.allowed[k] = true
+	}
+	for _, h := range splitList(c.BaggageHeaders) {
+		parts := strings.SplitN(h, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid baggage header mapping %q", h)
+		}
+		b.headers[http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return b, nil
+}
+
+func (b *Baggage) Allowed(key string) bool {
+	return b.allowAll || b.allowed[key]
+}
+
+// AttributeKeys are the baggage entries copied onto spans and log entries.
+func (b *Baggage) AttributeKeys() []string {
+	return b.attributes
+}
+
+// Filter drops the entries which are not in the allow-list and, in key
+// order, the entries beyond the count and size caps.
+func (b *Baggage) Filter(bg baggage.Baggage) baggage.Baggage {
+	members := bg.Members()
+	sort.Slice(members, func(i, j int) bool { return members[i].Key() < members[j].Key() })
+	count, size := 0, 0
+	for _, m := range members {
+		n := len(m.String())
+		if b.Allowed(m.Key()) && count < maxBaggageMembers && size+n <= maxBaggageBytes {
+			count++
+			size += n
+			continue
+		}
+		bg = bg.DeleteMember(m.Key())
+	}
+	return bg
+}
+
+// FromHeaders adds baggage entries for the mapped request headers.
+func (b *Baggage) FromHeaders(ctx context.Context, h http.Header) context.Context {
+	return b.FromCarrier(ctx, propagation.HeaderCarrier(h))
+}
+
+// FromCarrier adds baggage entries for the mapped headers of any carrier,
+// e.g. gRPC metadata or Kafka message headers.
+func (b *Baggage) FromCarrier(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if len(b.headers) == 0 {
+		return ctx
+	}
+	bg := baggage.FromContext(ctx)
+	for header, key := range b.headers {
+		v := carrier.Get(header)
+		if v == "" || !b.Allowed(key) {
+			continue
+		}
+		m, err := baggage.NewMember(key, v)
+		if err != nil {
+			continue
+		}
+		if nbg, err := bg.SetMember(m); err == nil {
+			bg = nbg
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, b.Filter(bg))
+}
+
+// Attributes returns the baggage entries of ctx which are copied onto spans.
+func (b *Baggage) Attributes(ctx context.Context) []attribute.KeyValue {
+	if len(b.attributes) == 0 {
+		return nil
+	}
+	bg := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, k := range b.attributes {
+		if m := bg.Member(k); m.Key() != "" {
+			attrs = append(attrs, attribute.String(k, m.Value()))
+		}
+	}
+	return attrs
+}
+
+type baggagePropagator struct {
+	propagation.Baggage
+	b *Baggage
+}
+
+func (p baggagePropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	ctx = p.Baggage.Extract(ctx, carrier)
+	return baggage.ContextWithBaggage(ctx, p.b.Filter(baggage.FromContext(ctx)))
+}
+
+type baggageSpanProcessor struct {
+	b *Baggage
+}
+
+func (p baggageSpanProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	s.SetAttributes(p.b.Attributes(parent)...)
+}
+
+func (baggageSpanProcessor) OnEnd(tracesdk.ReadOnlySpan)      {}
+func (baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func newTestBaggage(t *testing.T, members ...string) baggage.Baggage {
+	t.Helper()
+	bg, err := baggage.Parse(strings.Join(members, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bg
+}
+
+func TestBaggageFilter(t *testing.T) {
+	for _, tc := range []struct {
+		keys string
+		want int
+	}{
+		{keys: "", want: 0},
+		{keys: "tenant.id", want: 1},
+		{keys: "*", want: 2},
+	} {
+		b, err := NewBaggage(&config.Config{BaggageKeys: tc.keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bg := b.Filter(newTestBaggage(t, "tenant.id=acme", "user.email=a"))
+		if got := bg.Len(); got != tc.want {
+			t.Errorf("BaggageKeys %q: kept %d entries (%s), want %d", tc.keys, got, bg, tc.want)
+		}
+	}
+}
+
+func TestBaggageFilterCaps(t *testing.T) {
+	b, err := NewBaggage(&config.Config{BaggageKeys: BaggageAllowAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []string
+	for i := 0; i < 2*maxBaggageMembers; i++ {
+		members = append(members, fmt.Sprintf("k%02d=v", i))
+	}
+	if got := b.Filter(newTestBaggage(t, members...)).Len(); got != maxBaggageMembers {
+		t.Errorf("kept %d entries, want %d", got, maxBaggageMembers)
+	}
+
+	big := strings.Repeat("x", maxBaggageBytes/2)
+	bg := b.Filter(newTestBaggage(t, "a="+big, "b="+big, "c=small"))
+	if bg.Member("a").Key() == "" || bg.Member("b").Key() != "" || bg.Member("c").Key() == "" {
+		t.Errorf("kept %s, want a and c", bg)
+	}
+}
+
+func TestBaggageFromHeaders(t *testing.T) {
+	b, err := NewBaggage(&config.Config{
+		BaggageKeys:    "tenant.id",
+		BaggageHeaders: "X-Tenant-Id=tenant.id,X-Channel=channel",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{}
+	h.Set("X-Tenant-Id", "acme")
+	h.Set("X-Channel", "web")
+	bg := baggage.FromContext(b.FromHeaders(context.Background(), h))
+	if got := bg.Member("tenant.id").Value(); got != "acme" {
+		t.Errorf("tenant.id = %q, want acme", got)
+	}
+	if bg.Member("channel").Key() != "" {
+		t.Error("channel is not allowed but was added")
+	}
+}
@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type clientError struct {
+	err error
+}
+
+func (e clientError) Error() string { return e.err.Error() }
+func (e clientError) Unwrap() error { return e.err }
+
+// ClientError marks err as caused by the caller (bad input, missing entity),
+// such errors are recorded on spans but don't fail them.
+func ClientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return clientError{err: err}
+}
+
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string { return e.err.Error() }
+func (e notFoundError) Unwrap() error { return e.err }
+
+// NotFoundError marks err as caused by a missing entity, it is a client error
+// that transports report as not found.
+func NotFoundError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return notFoundError{err: err}
+}
+
+func IsNotFound(err error) bool {
+	var nfe notFoundError
+	return errors.As(err, &nfe)
+}
+
+func IsClientError(err error) bool {
+	var (
+		ce  clientError
+		se  *json.SyntaxError
+		ute *json.UnmarshalTypeError
+	)
+	return errors.As(err, &ce) || IsNotFound(err) || errors.As(err, &se) || errors.As(err, &ute)
+}
+
+// RecordError adds an exception event with a stacktrace to the span and, if
+// the failure is on our side, sets the span status to Error.
+func RecordError(span trace.Span, err error, serverFault bool) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	span.RecordError(err, trace.WithStackTrace(true))
+	if serverFault {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,285 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/multierr"
+)
+
+const (
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterJaeger   = "jaeger"
+	ExporterStdout   = "stdout"
+	ExporterFile     = "file"
+	ExporterNone     = "none"
+)
+
+// ExporterSpec is parsed from a TraceExporters entry, e.g.
+// "otlp-grpc?endpoint=collector:4317&insecure=true&batch_timeout=2s".
+type ExporterSpec struct {
+	Kind               string
+	Endpoint           string
+	Insecure           bool
+	Sync               bool
+	BatchTimeout       time.Duration
+	ExportTimeout      time.Duration
+	MaxQueueSize       int
+	MaxExportBatchSize int
+	// Headers are sent with every OTLP export, set with header=key:value.
+	Headers map[string]string
+	// Compression gzips OTLP/HTTP exports, on by default.
+	Compression bool
+}
+
+func defaultEndpoint(kind string, c *config.Config) string {
+	switch kind {
+	case ExporterOTLPGRPC:
+		return c.OtlpGRPCurl
+	case ExporterOTLPHTTP:
+		return c.OtlpHTTPurl
+	case ExporterJaeger:
+		return c.JaegerURL
+	case ExporterFile:
+		if c.TraceFilePath != "" {
+			return c.TraceFilePath
+		}
+		return "./logs/traces.jsonl"
+	}
+	return ""
+}
+
+func parseExporterSpec(entry string, c *config.Config) (ExporterSpec, error) {
+	kind, rawQuery := entry, ""
+	if i := strings.Index(entry, "?"); i >= 0 {
+		kind, rawQuery = entry[:i], entry[i+1:]
+	}
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterJaeger, ExporterStdout, ExporterFile, ExporterNone:
+	default:
+		return ExporterSpec{}, fmt.Errorf("unsupported trace exporter %q", kind)
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return ExporterSpec{}, fmt.Errorf("invalid %s exporter options: %w", kind, err)
+	}
+
+	spec := ExporterSpec{Kind: kind, Endpoint: defaultEndpoint(kind, c), Insecure: true, Compression: true}
+	for key := range q {
+		v := q.Get(key)
+		switch key {
+		case "header":
+			spec.Headers = map[string]string{}
+			for _, h := range q[key] {
+				kv := strings.SplitN(h, ":", 2)
+				if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+					err = fmt.Errorf("invalid header %q, want key:value", h)
+					break
+				}
+				spec.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		case "compression":
+			switch v {
+			case "gzip":
+				spec.Compression = true
+			case "none":
+				spec.Compression = false
+			default:
+				err = fmt.Errorf("unsupported compression %q", v)
+			}
+		case "endpoint", "path":
+			spec.Endpoint = v
+		case "insecure":
+			spec.Insecure, err = strconv.ParseBool(v)
+		case "sync":
+			spec.Sync, err = strconv.ParseBool(v)
+		case "batch_timeout":
+			spec.BatchTimeout, err = time.ParseDuration(v)
+		case "export_timeout":
+			spec.ExportTimeout, err = time.ParseDuration(v)
+		case "max_queue":
+			spec.MaxQueueSize, err = strconv.Atoi(v)
+		case "max_batch":
+			spec.MaxExportBatchSize, err = strconv.Atoi(v)
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return ExporterSpec{}, fmt.Errorf("invalid %s exporter options: %w", kind, err)
+		}
+	}
+	return spec, nil
+}
+
+func ParseExporters(c *config.Config) ([]ExporterSpec, error) {
+	raw := c.TraceExporters
+	if strings.TrimSpace(raw) == "" {
+		raw = ExporterJaeger
+	}
+	var specs []ExporterSpec
+	for _, entry := range strings.Split(raw, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		spec, err := parseExporterSpec(entry, c)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	for _, spec := range specs {
+		if spec.Kind != ExporterNone {
+			continue
+		}
+		if len(specs) > 1 {
+			return nil, fmt.Errorf("trace exporter %q can't be combined with other exporters", ExporterNone)
+		}
+		return nil, nil
+	}
+	return specs, nil
+}
+
+func (s ExporterSpec) batchOptions() []tracesdk.BatchSpanProcessorOption {
+	var opts []tracesdk.BatchSpanProcessorOption
+	if s.BatchTimeout > 0 {
+		opts = append(opts, tracesdk.WithBatchTimeout(s.BatchTimeout))
+	}
+	if s.ExportTimeout > 0 {
+		opts = append(opts, tracesdk.WithExportTimeout(s.ExportTimeout))
+	}
+	if s.MaxQueueSize > 0 {
+		opts = append(opts, tracesdk.WithMaxQueueSize(s.MaxQueueSize))
+	}
+	if s.MaxExportBatchSize > 0 {
+		opts = append(opts, tracesdk.WithMaxExportBatchSize(s.MaxExportBatchSize))
+	}
+	return opts
+}
+
+func newExporter(ctx context.Context, spec ExporterSpec) (tracesdk.SpanExporter, error) {
+	switch spec.Kind {
+	case ExporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(spec.Endpoint)}
+		if spec.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(spec.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(spec.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		opts, err := otlpHTTPOptions(spec)
+		if err != nil {
+			return nil, err
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterJaeger:
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(spec.Endpoint)))
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		if err := os.MkdirAll(filepath.Dir(spec.Endpoint), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(spec.Endpoint, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return fileExporter{Exporter: exp, f: f}, nil
+	}
+	return nil, fmt.Errorf("unsupported trace exporter %q", spec.Kind)
+}
+
+// NewSpanProcessors builds a processor per TraceExporters entry, exports are
+// measured and reported to h.
+func NewSpanProcessors(ctx context.Context, c *config.Config, h *Health, m meter.Meter) ([]tracesdk.SpanProcessor, error) {
+	specs, err := ParseExporters(c)
+	if err != nil {
+		return nil, err
+	}
+	processors := make([]tracesdk.SpanProcessor, 0, len(specs))
+	for _, spec := range specs {
+		exp, err := newExporter(ctx, spec)
+		if err != nil {
+			for _, p := range processors {
+				_ = p.Shutdown(ctx)
+			}
+			return nil, fmt.Errorf("%s exporter: %w", spec.Kind, err)
+		}
+		maxQueue, maxBatch := spec.MaxQueueSize, spec.MaxExportBatchSize
+		if maxQueue <= 0 {
+			maxQueue = tracesdk.DefaultMaxQueueSize
+		}
+		if maxBatch <= 0 {
+			maxBatch = tracesdk.DefaultMaxExportBatchSize
+		}
+		stats := newExporterStats(spec.Kind, maxQueue+maxBatch, h, m)
+		exp = statsExporter{SpanExporter: exp, stats: stats}
+		if spec.Sync {
+			processors = append(processors, tracesdk.NewSimpleSpanProcessor(exp))
+			continue
+		}
+		bsp := tracesdk.NewBatchSpanProcessor(exp, spec.batchOptions()...)
+		processors = append(processors, queueSpanProcessor{SpanProcessor: bsp, stats: stats})
+	}
+	return processors, nil
+}
+
+// fileExporter closes the trace file on shutdown, stdouttrace leaves the
+// writer open.
+type fileExporter struct {
+	*stdouttrace.Exporter
+	f *os.File
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	return multierr.Append(e.Exporter.Shutdown(ctx), e.f.Close())
+}
+
+// otlpHTTPOptions accepts both host:port and full URL endpoints, a URL scheme
+// overrides the insecure option.
+func otlpHTTPOptions(spec ExporterSpec) ([]otlptracehttp.Option, error) {
+	endpoint, insecure := spec.Endpoint, spec.Insecure
+	var opts []otlptracehttp.Option
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid otlp-http endpoint %q: %w", endpoint, err)
+		}
+		endpoint, insecure = u.Host, u.Scheme == "http"
+		if u.Path != "" && u.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+	opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if spec.Compression {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if len(spec.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(spec.Headers))
+	}
+	return opts, nil
+}
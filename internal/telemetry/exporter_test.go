@@ -0,0 +1,22 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileExporterCreatesDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "traces.jsonl")
+	exp, err := newExporter(context.Background(), ExporterSpec{Kind: ExporterFile, Endpoint: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("trace file wasn't created: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// errorLogInterval is how often the same SDK error is logged.
+	errorLogInterval = 10 * time.Second
+	// maxErrorKeys bounds the distinct messages tracked by the rate limiter.
+	maxErrorKeys = 100
+)
+
+type componentState struct {
+	err   string
+	errAt time.Time
+	okAt  time.Time
+}
+
+// Health tracks the state of the telemetry pipeline: span exporters and
+// metric collection. A component is degraded when its last operation failed.
+type Health struct {
+	mu         sync.Mutex
+	components map[string]*componentState
+	checks     map[string]func() error
+	exporters  []*exporterStats
+}
+
+func NewHealth() *Health {
+	return &Health{components: map[string]*componentState{}, checks: map[string]func() error{}}
+}
+
+// Watch adds a component whose state is queried with fn.
+func (h *Health) Watch(component string, fn func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[component] = fn
+}
+
+func (h *Health) state(component string) *componentState {
+	s, ok := h.components[component]
+	if !ok {
+		s = &componentState{}
+		h.components[component] = s
+	}
+	return s
+}
+
+// Report records the outcome of an operation of the component.
+func (h *Health) Report(component string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.state(component)
+	if err != nil {
+		s.err, s.errAt = err.Error(), time.Now()
+		return
+	}
+	s.okAt = time.Now()
+}
+
+// Degraded returns the failing components with their last error.
+func (h *Health) Degraded() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := map[string]string{}
+	for name, s := range h.components {
+		if s.errAt.After(s.okAt) {
+			res[name] = s.err
+		}
+	}
+	for name, fn := range h.checks {
+		if err := fn(); err != nil {
+			res[name] = err.Error()
+		}
+	}
+	return res
+}
+
+// ServeHTTP answers 200 when the pipeline is healthy and 503 with the failing
+// components otherwise.
+func (h *Health) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	degraded := h.Degraded()
+	res := struct {
+		Status     string            `json:"status"`
+		Components map[string]string `json:"degraded,omitempty"`
+	}{Status: "ok", Components: degraded}
+	w.Header().Set("content-type", "application/json")
+	if len(degraded) > 0 {
+		res.Status = "degraded"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *Health) register(m meter.Meter) error {
+	err := m.Gauge(meter.ExporterQueueSize, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		h.mu.Lock()
+		exporters := append([]*exporterStats(nil), h.exporters...)
+		h.mu.Unlock()
+		for _, s := range exporters {
+			s.mu.Lock()
+			observe(float64(s.queued), s.attrs...)
+			s.mu.Unlock()
+		}
+	})
+	return multierr.Append(err, m.Gauge(meter.TelemetryDegraded, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		h.mu.Lock()
+		names := make([]string, 0, len(h.components)+len(h.checks))
+		for name := range h.components {
+			names = append(names, name)
+		}
+		for name := range h.checks {
+			names = append(names, name)
+		}
+		h.mu.Unlock()
+		sort.Strings(names)
+		degraded := h.Degraded()
+		for _, name := range names {
+			v := 0.0
+			if _, ok := degraded[name]; ok {
+				v = 1
+			}
+			observe(v, attribute.String("component", name))
+		}
+	}))
+}
+
+type loggedError struct {
+	at         time.Time
+	suppressed int
+}
+
+// errorHandler logs errors reported by the otel SDK through otel.Handle, the
+// same message is logged at most once per errorLogInterval.
+type errorHandler struct {
+	log    *zap.Logger
+	errors meter.Counter
+	mu     sync.Mutex
+	seen   map[string]*loggedError
+}
+
+func newErrorHandler(log *zap.Logger, m meter.Meter) *errorHandler {
+	return &errorHandler{log: log, errors: m.Counter(meter.TelemetryErrors), seen: map[string]*loggedError{}}
+}
+
+func (h *errorHandler) Handle(err error) {
+	if err == nil {
+		return
+	}
+	h.errors.Add(context.Background(), 1)
+	msg := err.Error()
+	now := time.Now()
+
+	h.mu.Lock()
+	e, ok := h.seen[msg]
+	if ok && now.Sub(e.at) < errorLogInterval {
+		e.suppressed++
+		h.mu.Unlock()
+		return
+	}
+	if !ok {
+		if len(h.seen) >= maxErrorKeys {
+			h.seen = map[string]*loggedError{}
+		}
+		e = &loggedError{}
+		h.seen[msg] = e
+	}
+	suppressed := e.suppressed
+	e.at, e.suppressed = now, 0
+	h.mu.Unlock()
+
+	h.log.Error("telemetry error", zap.Error(err), zap.Int("suppressed", suppressed))
+}
+
+// exporterStats counts what happens to the spans handed to one exporter.
+type exporterStats struct {
+	component string
+	attrs     []attribute.KeyValue
+	health    *Health
+	exported  meter.Counter
+	failed    meter.Counter
+	dropped   meter.Counter
+	failures  meter.Counter
+	duration  meter.Histogram
+	mu        sync.Mutex
+	// queued counts the spans handed to the batch processor and not exported
+	// yet, capacity is what it can hold: its queue and the batch in export.
+	queued   int
+	capacity int
+}
+
+func newExporterStats(kind string, capacity int, h *Health, m meter.Meter) *exporterStats {
+	s := &exporterStats{
+		component: "exporter/" + kind,
+		attrs:     []attribute.KeyValue{attribute.String("exporter", kind)},
+		health:    h,
+		exported:  m.Counter(meter.ExporterSpansExported),
+		failed:    m.Counter(meter.ExporterSpansFailed),
+		dropped:   m.Counter(meter.ExporterSpansDropped),
+		failures:  m.Counter(meter.ExporterFailures),
+		duration:  m.Histogram(meter.ExporterDuration),
+		capacity:  capacity,
+	}
+	h.mu.Lock()
+	h.exporters = append(h.exporters, s)
+	h.mu.Unlock()
+	return s
+}
+
+// enqueue counts a span handed to the batch processor. The processor drops
+// spans silently when its queue is full, the spans beyond its capacity were
+// surely dropped and are counted as such, which also keeps queued from
+// drifting.
+func (s *exporterStats) enqueue() {
+	s.mu.Lock()
+	s.queued++
+	full := s.queued > s.capacity
+	if full {
+		s.queued = s.capacity
+	}
+	s.mu.Unlock()
+	if full {
+		s.dropped.Add(context.Background(), 1, s.attrs...)
+		s.health.Report(s.component, errQueueFull)
+	}
+}
+
+func (s *exporterStats) dequeue(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued -= n; s.queued < 0 {
+		s.queued = 0
+	}
+}
+
+var errQueueFull = queueFullError{}
+
+type queueFullError struct{}
+
+func (queueFullError) Error() string { return "span queue is full, spans are dropped" }
+
+// statsExporter measures every export of the wrapped exporter.
+type statsExporter struct {
+	tracesdk.SpanExporter
+	stats *exporterStats
+}
+
+func (e statsExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	s := e.stats
+	s.dequeue(len(spans))
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	s.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), s.attrs...)
+	if err != nil {
+		s.failed.Add(ctx, float64(len(spans)), s.attrs...)
+		s.failures.Add(ctx, 1, s.attrs...)
+	} else {
+		s.exported.Add(ctx, float64(len(spans)), s.attrs...)
+	}
+	s.health.Report(s.component, err)
+	return err
+}
+
+// queueSpanProcessor keeps track of the spans in the batch processor for the
+// queue size gauge, the batch processor alone decides what to drop.
+type queueSpanProcessor struct {
+	tracesdk.SpanProcessor
+	stats *exporterStats
+}
+
+func (p queueSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.stats.enqueue()
+	}
+	p.SpanProcessor.OnEnd(s)
+}
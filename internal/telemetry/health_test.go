@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestQueueSpanProcessor(t *testing.T) {
+	m, _ := meter.NewManualMeter(zap.NewNop(), resource.Empty())
+	h := NewHealth()
+	stats := newExporterStats("test", 2, h, m)
+	rec := tracetest.NewSpanRecorder()
+	tracer := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(queueSpanProcessor{SpanProcessor: rec, stats: stats})).Tracer("test")
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "span")
+		span.End()
+	}
+
+	// the batch processor decides what to drop, every span is handed to it
+	if n := len(rec.Ended()); n != 3 {
+		t.Fatalf("forwarded %d spans, want 3", n)
+	}
+	if stats.queued != 2 {
+		t.Errorf("queued = %d, want the capacity 2", stats.queued)
+	}
+	if _, ok := h.Degraded()[stats.component]; !ok {
+		t.Error("spans beyond the capacity didn't degrade the exporter")
+	}
+
+	exp := statsExporter{SpanExporter: tracetest.NewNoopExporter(), stats: stats}
+	if err := exp.ExportSpans(context.Background(), rec.Ended()[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if stats.queued != 0 {
+		t.Errorf("queued = %d after the export, want 0", stats.queued)
+	}
+}
@@ -0,0 +1,138 @@
+package meter
+
+import "go.opentelemetry.io/otel/metric/unit"
+
+const (
+	HTTPServerRequests       = "http.server.requests"
+	HTTPServerDuration       = "http.server.duration"
+	HTTPServerActiveRequests = "http.server.active_requests"
+
+	RPCServerRequests = "rpc.server.requests"
+	RPCServerDuration = "rpc.server.duration"
+	RPCClientDuration = "rpc.client.duration"
+
+	MessagingConsumerMessages = "messaging.consumer.messages"
+	MessagingConsumerDuration = "messaging.consumer.duration"
+	MessagingConsumerActive   = "messaging.consumer.active"
+
+	KafkaProducerMessages   = "messaging.kafka.producer.messages"
+	KafkaProducerBytes      = "messaging.kafka.producer.bytes"
+	KafkaProducerErrors     = "messaging.kafka.producer.errors"
+	KafkaProducerWriteTime  = "messaging.kafka.producer.write_time"
+	KafkaConsumerMessages   = "messaging.kafka.consumer.messages"
+	KafkaConsumerBytes      = "messaging.kafka.consumer.bytes"
+	KafkaConsumerErrors     = "messaging.kafka.consumer.errors"
+	KafkaConsumerRebalances = "messaging.kafka.consumer.rebalances"
+	KafkaConsumerFetchTime  = "messaging.kafka.consumer.fetch_time"
+	KafkaConsumerLag        = "messaging.kafka.consumer.lag"
+
+	DBClientDuration                 = "db.client.duration"
+	DBClientConnectionsUsage         = "db.client.connections.usage"
+	DBClientConnectionsCheckoutFails = "db.client.connections.checkout_failures"
+	DBClientConnectionsMax           = "db.client.connections.max"
+	DBClientConnectionsWaitCount     = "db.client.connections.wait_count"
+	DBClientConnectionsWaitTime      = "db.client.connections.wait_time"
+
+	RuntimeGoroutines   = "runtime.go.goroutines"
+	RuntimeHeapAlloc    = "runtime.go.mem.heap_alloc"
+	RuntimeHeapInuse    = "runtime.go.mem.heap_inuse"
+	RuntimeHeapSys      = "runtime.go.mem.heap_sys"
+	RuntimeHeapObjects  = "runtime.go.mem.heap_objects"
+	RuntimeGCCount      = "runtime.go.gc.count"
+	RuntimeGCPauseTotal = "runtime.go.gc.pause_total"
+	RuntimeGCPause      = "runtime.go.gc.pause"
+	RuntimeGCTargetHeap = "runtime.go.gc.target_heap"
+	ProcessCPUTime      = "process.cpu.time"
+	ProcessOpenFDs      = "process.open_fds"
+	ProcessUptime       = "process.uptime"
+
+	SpanCalls                  = "traces.span.calls"
+	SpanErrors                 = "traces.span.errors"
+	SpanDuration               = "traces.span.duration"
+	ServiceGraphRequests       = "traces.service_graph.requests"
+	ServiceGraphFailedRequests = "traces.service_graph.requests.failed"
+	ServiceGraphDuration       = "traces.service_graph.duration"
+
+	TelemetryErrors         = "otel.sdk.errors"
+	TelemetryDegraded       = "otel.sdk.degraded"
+	ExporterSpansExported   = "otel.exporter.spans.exported"
+	ExporterSpansFailed     = "otel.exporter.spans.failed"
+	ExporterSpansDropped    = "otel.exporter.spans.dropped"
+	ExporterQueueSize       = "otel.exporter.queue.size"
+	ExporterFailures        = "otel.exporter.export.failures"
+	ExporterDuration        = "otel.exporter.export.duration"
+	MetricsCollectionErrors = "otel.metrics.collection.errors"
+
+	TailSamplingTraces        = "otel.tail_sampling.traces"
+	TailSamplingBufferedSpans = "otel.tail_sampling.buffered_spans"
+)
+
+const (
+	unitSeconds      unit.Unit = "s"
+	unitMicroseconds unit.Unit = "us"
+)
+
+var DefaultCatalogue = []Definition{
+	{Name: HTTPServerRequests, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of HTTP requests"},
+	{Name: HTTPServerDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of inbound HTTP requests"},
+	{Name: HTTPServerActiveRequests, Kind: KindUpDownCounter, Unit: unit.Dimensionless, Description: "number of in-flight HTTP requests"},
+
+	{Name: RPCServerRequests, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of handled gRPC calls"},
+	{Name: RPCServerDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of inbound gRPC calls"},
+	{Name: RPCClientDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of outbound gRPC calls"},
+
+	{Name: MessagingConsumerMessages, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of consumed messages"},
+	{Name: MessagingConsumerDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of message processing"},
+	{Name: MessagingConsumerActive, Kind: KindUpDownCounter, Unit: unit.Dimensionless, Description: "number of messages being processed"},
+
+	{Name: KafkaProducerMessages, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of messages written by the kafka writer"},
+	{Name: KafkaProducerBytes, Kind: KindObservableCounter, Unit: unit.Bytes, Description: "number of bytes written by the kafka writer"},
+	{Name: KafkaProducerErrors, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of kafka writer errors"},
+	{Name: KafkaProducerWriteTime, Kind: KindGauge, Unit: unit.Milliseconds, Description: "kafka write latency over the last stats interval"},
+	{Name: KafkaConsumerMessages, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of messages fetched by the kafka reader"},
+	{Name: KafkaConsumerBytes, Kind: KindObservableCounter, Unit: unit.Bytes, Description: "number of bytes fetched by the kafka reader"},
+	{Name: KafkaConsumerErrors, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of kafka reader errors"},
+	{Name: KafkaConsumerRebalances, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of consumer group rebalances"},
+	{Name: KafkaConsumerFetchTime, Kind: KindGauge, Unit: unit.Milliseconds, Description: "kafka fetch latency over the last stats interval"},
+	{Name: KafkaConsumerLag, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of messages the consumer group is behind per partition"},
+
+	{Name: DBClientDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of database commands"},
+	{Name: DBClientConnectionsUsage, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of pooled connections by state"},
+	{Name: DBClientConnectionsCheckoutFails, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of failed connection checkouts"},
+	{Name: DBClientConnectionsMax, Kind: KindGauge, Unit: unit.Dimensionless, Description: "maximum number of open connections"},
+	{Name: DBClientConnectionsWaitCount, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "total number of connections waited for"},
+	{Name: DBClientConnectionsWaitTime, Kind: KindObservableCounter, Unit: unit.Milliseconds, Description: "total time blocked waiting for a connection"},
+
+	{Name: RuntimeGoroutines, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of live goroutines"},
+	{Name: RuntimeHeapAlloc, Kind: KindGauge, Unit: unit.Bytes, Description: "bytes of allocated heap objects"},
+	{Name: RuntimeHeapInuse, Kind: KindGauge, Unit: unit.Bytes, Description: "bytes in in-use heap spans"},
+	{Name: RuntimeHeapSys, Kind: KindGauge, Unit: unit.Bytes, Description: "bytes of heap memory obtained from the OS"},
+	{Name: RuntimeHeapObjects, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of allocated heap objects"},
+	{Name: RuntimeGCCount, Kind: KindObservableCounter, Unit: unit.Dimensionless, Description: "number of completed GC cycles"},
+	{Name: RuntimeGCPauseTotal, Kind: KindObservableCounter, Unit: unit.Milliseconds, Description: "total stop-the-world GC pause time"},
+	{Name: RuntimeGCPause, Kind: KindHistogram, Unit: unitMicroseconds, Description: "stop-the-world GC pauses in microseconds"},
+	{Name: RuntimeGCTargetHeap, Kind: KindGauge, Unit: unit.Bytes, Description: "heap size target of the next GC cycle"},
+	{Name: ProcessCPUTime, Kind: KindObservableCounter, Unit: unitSeconds, Description: "CPU time spent by the process by state"},
+	{Name: ProcessOpenFDs, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of open file descriptors"},
+	{Name: ProcessUptime, Kind: KindGauge, Unit: unitSeconds, Description: "time since the process started"},
+
+	{Name: SpanCalls, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of finished spans by operation, kind and status"},
+	{Name: SpanErrors, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of finished spans with an error status"},
+	{Name: SpanDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of finished spans"},
+	{Name: ServiceGraphRequests, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of requests between two services"},
+	{Name: ServiceGraphFailedRequests, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of failed requests between two services"},
+	{Name: ServiceGraphDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of requests between two services measured by the server"},
+
+	{Name: TelemetryErrors, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of errors reported by the otel SDK"},
+	{Name: TelemetryDegraded, Kind: KindGauge, Unit: unit.Dimensionless, Description: "1 if the telemetry component is failing"},
+	{Name: ExporterSpansExported, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of spans exported"},
+	{Name: ExporterSpansFailed, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of spans in failed exports"},
+	{Name: ExporterSpansDropped, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of spans dropped because the export queue was full"},
+	{Name: ExporterQueueSize, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of spans waiting to be exported"},
+	{Name: ExporterFailures, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of failed exports"},
+	{Name: ExporterDuration, Kind: KindHistogram, Unit: unit.Milliseconds, Description: "duration of span exports"},
+	{Name: MetricsCollectionErrors, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of failed prometheus collections"},
+
+	{Name: TailSamplingTraces, Kind: KindCounter, Unit: unit.Dimensionless, Description: "number of tail sampling decisions by outcome and policy"},
+	{Name: TailSamplingBufferedSpans, Kind: KindGauge, Unit: unit.Dimensionless, Description: "number of spans waiting for a tail sampling decision"},
+}
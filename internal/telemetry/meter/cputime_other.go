@@ -0,0 +1,13 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package meter
+
+import (
+	"errors"
+	"time"
+)
+
+func cpuTime() (user, system time.Duration, err error) {
+	return 0, 0, errors.New("cpu time is not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package meter
+
+import (
+	"syscall"
+	"time"
+)
+
+func cpuTime() (user, system time.Duration, err error) {
+	var ru syscall.Rusage
+	if err = syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano()), nil
+}
@@ -0,0 +1,183 @@
+package meter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	exemplarTraceIDKey = "trace_id"
+	exemplarSpanIDKey  = "span_id"
+	// exemplarMaxAge drops exemplars of buckets which saw no sampled request
+	// for a while, their traces are likely gone from the backend.
+	exemplarMaxAge = 5 * time.Minute
+)
+
+// exemplars keeps the last sampled trace of every histogram bucket. The otel
+// SDK has no exemplar support yet, so they are attached to the gathered
+// metric families before they are served in the OpenMetrics format.
+type exemplars struct {
+	mu         sync.Mutex
+	boundaries []float64
+	resource   map[string]string
+	// metric name -> label key -> exemplar per bucket
+	buckets map[string]map[string][]*dto.Exemplar
+}
+
+func newExemplars(res *resource.Resource, boundaries []float64) *exemplars {
+	e := &exemplars{
+		boundaries: boundaries,
+		resource:   map[string]string{},
+		buckets:    map[string]map[string][]*dto.Exemplar{},
+	}
+	for _, kv := range res.Attributes() {
+		e.resource[sanitize(string(kv.Key))] = kv.Value.Emit()
+	}
+	return e
+}
+
+// sanitize mirrors the name mangling of the otel prometheus exporter.
+func sanitize(s string) string {
+	if s == "" {
+		return s
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	if unicode.IsDigit(rune(s[0])) {
+		s = "key_" + s
+	}
+	if s[0] == '_' {
+		s = "key" + s
+	}
+	return s
+}
+
+// labelKey identifies a series by its labels, resource labels are left out
+// since they are the same for every series.
+func (e *exemplars) labelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if rv, ok := e.resource[k]; ok && rv == v {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+func (e *exemplars) record(ctx context.Context, name string, value float64, attrs []attribute.KeyValue) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return
+	}
+	bucket := sort.SearchFloat64s(e.boundaries, value)
+	if bucket == len(e.boundaries) {
+		// the +Inf bucket is added by the encoder and can't carry one
+		return
+	}
+	set := attribute.NewSet(attrs...)
+	labels := make(map[string]string, set.Len())
+	for _, kv := range set.ToSlice() {
+		labels[sanitize(string(kv.Key))] = kv.Value.Emit()
+	}
+	ex := &dto.Exemplar{
+		Label: []*dto.LabelPair{
+			{Name: proto.String(exemplarTraceIDKey), Value: proto.String(sc.TraceID().String())},
+			{Name: proto.String(exemplarSpanIDKey), Value: proto.String(sc.SpanID().String())},
+		},
+		Value:     proto.Float64(value),
+		Timestamp: timestamppb.New(time.Now()),
+	}
+	key := e.labelKey(labels)
+	name = sanitize(name)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	series, ok := e.buckets[name]
+	if !ok {
+		series = map[string][]*dto.Exemplar{}
+		e.buckets[name] = series
+	}
+	if series[key] == nil {
+		series[key] = make([]*dto.Exemplar, len(e.boundaries))
+	}
+	series[key][bucket] = ex
+}
+
+// expire forgets the exemplars older than exemplarMaxAge and the series left
+// without any, e.mu must be held.
+func (e *exemplars) expire(now time.Time) {
+	for name, series := range e.buckets {
+		for key, ex := range series {
+			live := false
+			for i, x := range ex {
+				if x == nil {
+					continue
+				}
+				if now.Sub(x.GetTimestamp().AsTime()) > exemplarMaxAge {
+					ex[i] = nil
+					continue
+				}
+				live = true
+			}
+			if !live {
+				delete(series, key)
+			}
+		}
+		if len(series) == 0 {
+			delete(e.buckets, name)
+		}
+	}
+}
+
+// attach sets the exemplars on the buckets of the gathered histograms.
+func (e *exemplars) attach(mfs []*dto.MetricFamily, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expire(now)
+	for _, mf := range mfs {
+		series, ok := e.buckets[mf.GetName()]
+		if !ok || mf.GetType() != dto.MetricType_HISTOGRAM {
+			continue
+		}
+		for _, m := range mf.Metric {
+			labels := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			ex, ok := series[e.labelKey(labels)]
+			if !ok {
+				continue
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				i := sort.SearchFloat64s(e.boundaries, b.GetUpperBound())
+				if i < len(ex) && e.boundaries[i] == b.GetUpperBound() && ex[i] != nil {
+					b.Exemplar = ex[i]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package meter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+func histogramFamily(name string, bound float64, labels ...*dto.LabelPair) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: proto.String(name),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{{
+			Label:     labels,
+			Histogram: &dto.Histogram{Bucket: []*dto.Bucket{{UpperBound: proto.Float64(bound)}}},
+		}},
+	}
+}
+
+func TestExemplarsExpire(t *testing.T) {
+	e := newExemplars(resource.Empty(), []float64{10, 100})
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	e.record(ctx, "http.server.duration", 5, []attribute.KeyValue{attribute.String("route", "/order")})
+
+	route := &dto.LabelPair{Name: proto.String("route"), Value: proto.String("/order")}
+	mf := histogramFamily("http_server_duration", 10, route)
+	e.attach([]*dto.MetricFamily{mf}, time.Now())
+	if mf.Metric[0].Histogram.Bucket[0].Exemplar == nil {
+		t.Fatal("fresh exemplar not attached")
+	}
+
+	mf = histogramFamily("http_server_duration", 10, route)
+	e.attach([]*dto.MetricFamily{mf}, time.Now().Add(exemplarMaxAge+time.Second))
+	if mf.Metric[0].Histogram.Bucket[0].Exemplar != nil {
+		t.Fatal("expired exemplar attached")
+	}
+	if len(e.buckets) != 0 {
+		t.Fatalf("kept %d metrics without live exemplars", len(e.buckets))
+	}
+}
@@ -2,8 +2,17 @@ package meter
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/morzhanov/go-otel/internal/config"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	export "go.opentelemetry.io/otel/sdk/export/metric"
@@ -11,47 +20,235 @@ import (
 	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
 	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
 	selector "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
+// DefaultHistogramBoundaries are tuned for millisecond durations.
+var DefaultHistogramBoundaries = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+const (
+	defaultMetricsAddr = ":2222"
+	defaultMetricsPath = "/metrics"
+	// MetricsAddrOff disables the dedicated metrics server, the handler can
+	// still be mounted on another server through Meter.Handler.
+	MetricsAddrOff = "off"
+)
+
 type mtr struct {
-	reqCount metric.Int64Counter
+	reg      *registry
+	ctrl     *controller.Controller
+	gatherer *gatherer
+	handler  http.Handler
+	mux      *http.ServeMux
+	srv      *http.Server
+	runtime  *runtimeStats
+	path     string
+	provider metric.MeterProvider
 }
 
 type Meter interface {
-	IncReqCount()
+	Counter(name string, opts ...Option) Counter
+	UpDownCounter(name string, opts ...Option) UpDownCounter
+	Histogram(name string, opts ...Option) Histogram
+	Gauge(name string, fn GaugeFunc, opts ...Option) error
+	ObservableCounter(name string, fn GaugeFunc, opts ...Option) error
+	Provider() metric.MeterProvider
+	Handler() http.Handler
+	HandlerPath() string
+	// Handle mounts h on the dedicated metrics server, it does nothing when
+	// the server is off.
+	Handle(pattern string, h http.Handler)
+	CollectError() error
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// gatherer attaches the exemplars to the gathered metrics and keeps the
+// result of the last collection.
+type gatherer struct {
+	promclient.Gatherer
+	ex     *exemplars
+	mu     sync.Mutex
+	err    error
+	errors Counter
+}
+
+func (g *gatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	g.ex.attach(mfs, time.Now())
+	g.mu.Lock()
+	g.err = err
+	errors := g.errors
+	g.mu.Unlock()
+	if err != nil && errors != nil {
+		errors.Add(context.Background(), 1)
+	}
+	return mfs, err
 }
 
-func InitMeter(log *zap.Logger) metric.MeterProvider {
-	conf := prometheus.Config{}
-	c := controller.New(
+func newController(res *resource.Resource, opts ...controller.Option) *controller.Controller {
+	return controller.New(
 		processor.New(
 			selector.NewWithHistogramDistribution(
-				histogram.WithExplicitBoundaries(conf.DefaultHistogramBoundaries),
+				histogram.WithExplicitBoundaries(DefaultHistogramBoundaries),
 			),
 			export.CumulativeExportKindSelector(),
 			processor.WithMemory(true),
 		),
+		append([]controller.Option{controller.WithResource(res)}, opts...)...,
 	)
-	exporter, err := prometheus.New(conf, c)
+}
+
+// initMeter sets up the controller and a handler serving its metrics in the
+// OpenMetrics format (the text format for older scrapers) with the histogram
+// exemplars recorded by the gatherer.
+func initMeter(res *resource.Resource, g *gatherer) (*controller.Controller, http.Handler, error) {
+	reg := promclient.NewRegistry()
+	g.Gatherer = reg
+	conf := prometheus.Config{
+		DefaultHistogramBoundaries: DefaultHistogramBoundaries,
+		Registry:                   reg,
+		Gatherer:                   g,
+	}
+	c := newController(res)
+	if _, err := prometheus.New(conf, c); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize prometheus exporter: %w", err)
+	}
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	return c, h, nil
+}
+
+func metricsPath(c *config.Config) string {
+	if c.MetricsPath == "" {
+		return defaultMetricsPath
+	}
+	return c.MetricsPath
+}
+
+func serveMetrics(addr string, mux *http.ServeMux, log *zap.Logger) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Error("failed to initialize prometheus exporter %v", zap.Error(err))
+		return nil, fmt.Errorf("failed to start prometheus server: %w", err)
 	}
-	http.HandleFunc("/", exporter.ServeHTTP)
+	srv := &http.Server{Handler: mux}
 	go func() {
-		_ = http.ListenAndServe(":2222", nil)
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error("prometheus server failed", zap.Error(err))
+		}
 	}()
-	log.Info("Prometheus server running on :2222")
-	return exporter.MeterProvider()
+	log.Info("Prometheus server running", zap.String("addr", lis.Addr().String()))
+	return srv, nil
 }
 
-func (m *mtr) IncReqCount() {
-	m.reqCount.Add(context.TODO(), 1)
+func (m *mtr) Counter(name string, opts ...Option) Counter {
+	return m.reg.counter(name, opts)
+}
+
+func (m *mtr) UpDownCounter(name string, opts ...Option) UpDownCounter {
+	return m.reg.upDownCounter(name, opts)
+}
+
+func (m *mtr) Histogram(name string, opts ...Option) Histogram {
+	return m.reg.histogram(name, opts)
+}
+
+func (m *mtr) Gauge(name string, fn GaugeFunc, opts ...Option) error {
+	return m.reg.observer(name, KindGauge, fn, opts)
+}
+
+// ObservableCounter registers a monotonic counter, fn must observe cumulative
+// totals.
+func (m *mtr) ObservableCounter(name string, fn GaugeFunc, opts ...Option) error {
+	return m.reg.observer(name, KindObservableCounter, fn, opts)
+}
+
+func (m *mtr) Provider() metric.MeterProvider {
+	return m.provider
+}
+
+func (m *mtr) Handler() http.Handler {
+	if m.handler == nil {
+		return http.NotFoundHandler()
+	}
+	return m.handler
+}
+
+func (m *mtr) HandlerPath() string {
+	return m.path
+}
+
+func (m *mtr) Handle(pattern string, h http.Handler) {
+	if m.mux != nil {
+		m.mux.Handle(pattern, h)
+	}
+}
+
+// CollectError returns the error of the last Prometheus collection.
+func (m *mtr) CollectError() error {
+	if m.gatherer == nil {
+		return nil
+	}
+	m.gatherer.mu.Lock()
+	defer m.gatherer.mu.Unlock()
+	return m.gatherer.err
+}
+
+func (m *mtr) ForceFlush(ctx context.Context) error {
+	return m.ctrl.Collect(ctx)
+}
+
+func (m *mtr) Shutdown(ctx context.Context) (err error) {
+	if m.runtime != nil {
+		m.runtime.stop()
+	}
+	if m.srv != nil {
+		err = multierr.Append(err, m.srv.Shutdown(ctx))
+	}
+	return multierr.Append(err, m.ctrl.Stop(ctx))
+}
+
+func NewMeter(c *config.Config, log *zap.Logger, res *resource.Resource) (Meter, error) {
+	interval, err := runtimeInterval(c)
+	if err != nil {
+		return nil, err
+	}
+	ex := newExemplars(res, DefaultHistogramBoundaries)
+	g := &gatherer{ex: ex}
+	ctrl, handler, err := initMeter(res, g)
+	if err != nil {
+		return nil, err
+	}
+	m := &mtr{ctrl: ctrl, gatherer: g, handler: handler, path: metricsPath(c), provider: ctrl.MeterProvider()}
+	if addr := c.MetricsAddr; addr != MetricsAddrOff {
+		if addr == "" {
+			addr = defaultMetricsAddr
+		}
+		m.mux = http.NewServeMux()
+		m.mux.Handle(m.path, handler)
+		if m.srv, err = serveMetrics(addr, m.mux, log); err != nil {
+			return nil, err
+		}
+	}
+	m.reg = newRegistry(m.provider.Meter("prometheus"), log, DefaultCatalogue, ex)
+	g.mu.Lock()
+	g.errors = m.Counter(MetricsCollectionErrors)
+	g.mu.Unlock()
+	if interval > 0 {
+		if m.runtime, err = startRuntimeMetrics(m, interval); err != nil {
+			_ = m.Shutdown(context.Background())
+			return nil, err
+		}
+	}
+	return m, nil
 }
 
-func NewMeter(log *zap.Logger) (Meter, error) {
-	provider := InitMeter(log)
-	prom := provider.Meter("prometheus")
-	rc, err := prom.NewInt64Counter("request_count")
-	return &mtr{reqCount: rc}, err
+// NewManualMeter builds a Meter without exporter, measurements are read from
+// the returned controller after Collect.
+func NewManualMeter(log *zap.Logger, res *resource.Resource) (Meter, *controller.Controller) {
+	ctrl := newController(res, controller.WithCollectPeriod(0))
+	m := &mtr{ctrl: ctrl, path: defaultMetricsPath, provider: ctrl.MeterProvider()}
+	m.reg = newRegistry(m.provider.Meter("prometheus"), log, DefaultCatalogue, nil)
+	return m, ctrl
 }
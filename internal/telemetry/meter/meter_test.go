@@ -0,0 +1,38 @@
+package meter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+)
+
+func TestMeterHandle(t *testing.T) {
+	m, err := NewMeter(&config.Config{MetricsAddr: "127.0.0.1:0", RuntimeMetricsInterval: RuntimeMetricsOff}, zap.NewNop(), resource.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	m.Handle("/ready", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	mux := m.(*mtr).mux
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{path: "/ready", want: http.StatusServiceUnavailable},
+		{path: defaultMetricsPath, want: http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("GET %s = %d, want %d", tc.path, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package meter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.uber.org/zap"
+)
+
+type Kind int
+
+const (
+	KindCounter Kind = iota
+	KindUpDownCounter
+	KindHistogram
+	KindGauge
+	KindObservableCounter
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindUpDownCounter:
+		return "up-down counter"
+	case KindHistogram:
+		return "histogram"
+	case KindGauge:
+		return "gauge"
+	case KindObservableCounter:
+		return "observable counter"
+	}
+	return "unknown"
+}
+
+type Definition struct {
+	Name        string
+	Kind        Kind
+	Unit        unit.Unit
+	Description string
+	Attributes  []attribute.KeyValue
+}
+
+type Option func(*Definition)
+
+func WithUnit(u unit.Unit) Option {
+	return func(d *Definition) { d.Unit = u }
+}
+
+func WithDescription(desc string) Option {
+	return func(d *Definition) { d.Description = desc }
+}
+
+// WithAttributes binds attributes which are added to every measurement of the
+// instrument.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(d *Definition) { d.Attributes = append(d.Attributes, attrs...) }
+}
+
+type Counter interface {
+	Add(ctx context.Context, value float64, attrs ...attribute.KeyValue)
+}
+
+type UpDownCounter interface {
+	Add(ctx context.Context, value float64, attrs ...attribute.KeyValue)
+}
+
+type Histogram interface {
+	Record(ctx context.Context, value float64, attrs ...attribute.KeyValue)
+}
+
+type GaugeFunc func(ctx context.Context, observe func(value float64, attrs ...attribute.KeyValue))
+
+type instrument struct {
+	def     Definition
+	counter metric.Float64Counter
+	updown  metric.Float64UpDownCounter
+	hist    metric.Float64Histogram
+	ex      *exemplars
+
+	// mu guards fns, the callbacks of an observer
+	mu  sync.Mutex
+	fns []GaugeFunc
+}
+
+func (i *instrument) attrs(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(i.def.Attributes) == 0 {
+		return attrs
+	}
+	return append(append(make([]attribute.KeyValue, 0, len(i.def.Attributes)+len(attrs)), i.def.Attributes...), attrs...)
+}
+
+func (i *instrument) Add(ctx context.Context, value float64, attrs ...attribute.KeyValue) {
+	switch i.def.Kind {
+	case KindCounter:
+		i.counter.Add(ctx, value, i.attrs(attrs)...)
+	case KindUpDownCounter:
+		i.updown.Add(ctx, value, i.attrs(attrs)...)
+	}
+}
+
+func (i *instrument) observe(ctx context.Context, res metric.Float64ObserverResult) {
+	i.mu.Lock()
+	fns := i.fns
+	i.mu.Unlock()
+	for _, fn := range fns {
+		fn(ctx, func(value float64, attrs ...attribute.KeyValue) {
+			res.Observe(value, i.attrs(attrs)...)
+		})
+	}
+}
+
+func (i *instrument) Record(ctx context.Context, value float64, attrs ...attribute.KeyValue) {
+	attrs = i.attrs(attrs)
+	i.hist.Record(ctx, value, attrs...)
+	if i.ex != nil {
+		i.ex.record(ctx, i.def.Name, value, attrs)
+	}
+}
+
+type noopInstrument struct{}
+
+func (noopInstrument) Add(context.Context, float64, ...attribute.KeyValue)    {}
+func (noopInstrument) Record(context.Context, float64, ...attribute.KeyValue) {}
+
+type registry struct {
+	mu          sync.Mutex
+	meter       metric.Meter
+	log         *zap.Logger
+	catalogue   map[string]Definition
+	instruments map[string]*instrument
+	exemplars   *exemplars
+}
+
+func newRegistry(m metric.Meter, log *zap.Logger, catalogue []Definition, ex *exemplars) *registry {
+	r := &registry{
+		meter:       m,
+		log:         log,
+		exemplars:   ex,
+		catalogue:   make(map[string]Definition, len(catalogue)),
+		instruments: map[string]*instrument{},
+	}
+	for _, d := range catalogue {
+		r.catalogue[d.Name] = d
+	}
+	return r
+}
+
+func (r *registry) definition(name string, kind Kind, opts []Option) Definition {
+	def, ok := r.catalogue[name]
+	if !ok {
+		def = Definition{Name: name, Kind: kind}
+	}
+	def.Attributes = append([]attribute.KeyValue(nil), def.Attributes...)
+	for _, o := range opts {
+		o(&def)
+	}
+	return def
+}
+
+func sameDefinition(a, b Definition) bool {
+	if a.Unit != b.Unit || a.Description != b.Description {
+		return false
+	}
+	as, bs := attribute.NewSet(a.Attributes...), attribute.NewSet(b.Attributes...)
+	return as.Equals(&bs)
+}
+
+func (r *registry) get(name string, kind Kind, opts []Option) (*instrument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.instruments[name]; ok {
+		if i.def.Kind != kind {
+			return nil, fmt.Errorf("instrument %q is already registered as %s", name, i.def.Kind)
+		}
+		if len(opts) > 0 && !sameDefinition(i.def, r.definition(name, kind, opts)) {
+			r.log.Warn("instrument is already registered with other options, they are ignored", zap.String("name", name))
+		}
+		return i, nil
+	}
+
+	def := r.definition(name, kind, opts)
+	if def.Kind != kind {
+		return nil, fmt.Errorf("instrument %q is declared as %s", name, def.Kind)
+	}
+	iopts := []metric.InstrumentOption{metric.WithUnit(def.Unit), metric.WithDescription(def.Description)}
+	i := &instrument{def: def}
+	var err error
+	switch kind {
+	case KindCounter:
+		i.counter, err = r.meter.NewFloat64Counter(name, iopts...)
+	case KindUpDownCounter:
+		i.updown, err = r.meter.NewFloat64UpDownCounter(name, iopts...)
+	case KindHistogram:
+		i.hist, err = r.meter.NewFloat64Histogram(name, iopts...)
+		i.ex = r.exemplars
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.instruments[name] = i
+	return i, nil
+}
+
+func (r *registry) counter(name string, opts []Option) Counter {
+	i, err := r.get(name, KindCounter, opts)
+	if err != nil {
+		r.log.Error("failed to register counter", zap.String("name", name), zap.Error(err))
+		return noopInstrument{}
+	}
+	return i
+}
+
+func (r *registry) upDownCounter(name string, opts []Option) UpDownCounter {
+	i, err := r.get(name, KindUpDownCounter, opts)
+	if err != nil {
+		r.log.Error("failed to register up-down counter", zap.String("name", name), zap.Error(err))
+		return noopInstrument{}
+	}
+	return i
+}
+
+func (r *registry) histogram(name string, opts []Option) Histogram {
+	i, err := r.get(name, KindHistogram, opts)
+	if err != nil {
+		r.log.Error("failed to register histogram", zap.String("name", name), zap.Error(err))
+		return noopInstrument{}
+	}
+	return i
+}
+
+// observer registers an observable instrument, registering the same name
+// and kind again adds fn to the callbacks of the existing one, so each caller
+// observes its own attributes, e.g. one client per topic.
+func (r *registry) observer(name string, kind Kind, fn GaugeFunc, opts []Option) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.instruments[name]; ok {
+		if i.def.Kind != kind {
+			return fmt.Errorf("instrument %q is already registered as %s", name, i.def.Kind)
+		}
+		if len(opts) > 0 && !sameDefinition(i.def, r.definition(name, kind, opts)) {
+			r.log.Warn("instrument is already registered with other options, they are ignored", zap.String("name", name))
+		}
+		i.mu.Lock()
+		i.fns = append(i.fns, fn)
+		i.mu.Unlock()
+		return nil
+	}
+	def := r.definition(name, kind, opts)
+	if def.Kind != kind {
+		return fmt.Errorf("instrument %q is declared as %s", name, def.Kind)
+	}
+	i := &instrument{def: def, fns: []GaugeFunc{fn}}
+	iopts := []metric.InstrumentOption{metric.WithUnit(def.Unit), metric.WithDescription(def.Description)}
+	var err error
+	if kind == KindObservableCounter {
+		_, err = r.meter.NewFloat64CounterObserver(name, i.observe, iopts...)
+	} else {
+		_, err = r.meter.NewFloat64GaugeObserver(name, i.observe, iopts...)
+	}
+	if err != nil {
+		return err
+	}
+	r.instruments[name] = i
+	return nil
+}
@@ -0,0 +1,76 @@
+package meter
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRegistryCachesInstruments(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	m, _ := NewManualMeter(zap.New(core), resource.Empty())
+
+	a := m.Counter("test.requests", WithAttributes(attribute.String("a", "1")))
+	if b := m.Counter("test.requests"); b != a {
+		t.Fatal("second lookup without options returned another instrument")
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("lookup without options logged %d entries", logs.Len())
+	}
+	if b := m.Counter("test.requests", WithAttributes(attribute.String("a", "2"))); b != a {
+		t.Fatal("lookup with other options returned another instrument")
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("lookup with other options logged %d entries, want 1", logs.Len())
+	}
+}
+
+func TestRegistryRejectsKindMismatch(t *testing.T) {
+	m, _ := NewManualMeter(zap.NewNop(), resource.Empty())
+	m.Counter("test.requests")
+	if _, ok := m.Histogram("test.requests").(noopInstrument); !ok {
+		t.Fatal("histogram with a counter name is not a noop")
+	}
+	if _, ok := m.Histogram(HTTPServerRequests).(noopInstrument); !ok {
+		t.Fatal("histogram with a catalogue counter name is not a noop")
+	}
+}
+
+func TestRegistrySharesObservers(t *testing.T) {
+	m, ctrl := NewManualMeter(zap.NewNop(), resource.Empty())
+	for _, topic := range []string{"orders", "payments"} {
+		topic := topic
+		err := m.Gauge("test.lag", func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+			observe(1, attribute.String("topic", topic))
+		})
+		if err != nil {
+			t.Fatalf("register %s: %v", topic, err)
+		}
+	}
+	if err := m.ObservableCounter("test.lag", nil); err == nil {
+		t.Fatal("observable counter with a gauge name registered")
+	}
+
+	if err := ctrl.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	topics := map[string]bool{}
+	err := ctrl.ForEach(export.CumulativeExportKindSelector(), func(r export.Record) error {
+		if v, ok := r.Labels().Value("topic"); ok && r.Descriptor().Name() == "test.lag" {
+			topics[v.AsString()] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !topics["orders"] || !topics["payments"] {
+		t.Fatalf("observed topics %v, want orders and payments", topics)
+	}
+}
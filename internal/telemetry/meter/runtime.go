@@ -0,0 +1,153 @@
+package meter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultRuntimeInterval = 10 * time.Second
+	// RuntimeMetricsOff disables runtime and process metrics.
+	RuntimeMetricsOff = "off"
+)
+
+var processStart = time.Now()
+
+// runtimeInterval parses RuntimeMetricsInterval, zero means disabled.
+func runtimeInterval(c *config.Config) (time.Duration, error) {
+	switch c.RuntimeMetricsInterval {
+	case "":
+		return defaultRuntimeInterval, nil
+	case RuntimeMetricsOff:
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.RuntimeMetricsInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid runtime metrics interval %q", c.RuntimeMetricsInterval)
+	}
+	return d, nil
+}
+
+// runtime.ReadMemStats stops the world, so the memory stats are read once per
+// interval and the observers report the last snapshot.
+type runtimeStats struct {
+	mu     sync.Mutex
+	mem    runtime.MemStats
+	numGC  uint32
+	pauses Histogram
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func (s *runtimeStats) poll() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := ms.NumGC - s.numGC
+	if n > uint32(len(ms.PauseNs)) {
+		n = uint32(len(ms.PauseNs))
+	}
+	for i := uint32(0); i < n; i++ {
+		pause := ms.PauseNs[(ms.NumGC-i+255)%256]
+		s.pauses.Record(context.Background(), float64(pause)/float64(time.Microsecond))
+	}
+	s.numGC = ms.NumGC
+	s.mem = ms
+}
+
+func (s *runtimeStats) run(interval time.Duration) {
+	defer s.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.poll()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *runtimeStats) stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *runtimeStats) memGauge(fn func(ms *runtime.MemStats) float64) GaugeFunc {
+	return func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		observe(fn(&s.mem))
+	}
+}
+
+func openFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	// one of them is the directory being read
+	return len(entries) - 1, nil
+}
+
+// startRuntimeMetrics registers goroutine, heap, GC and process instruments
+// and polls the memory stats every interval.
+func startRuntimeMetrics(m Meter, interval time.Duration) (*runtimeStats, error) {
+	s := &runtimeStats{pauses: m.Histogram(RuntimeGCPause), done: make(chan struct{})}
+	s.poll()
+
+	var err error
+	gauges := map[string]func(ms *runtime.MemStats) float64{
+		RuntimeHeapAlloc:    func(ms *runtime.MemStats) float64 { return float64(ms.HeapAlloc) },
+		RuntimeHeapInuse:    func(ms *runtime.MemStats) float64 { return float64(ms.HeapInuse) },
+		RuntimeHeapSys:      func(ms *runtime.MemStats) float64 { return float64(ms.HeapSys) },
+		RuntimeHeapObjects:  func(ms *runtime.MemStats) float64 { return float64(ms.HeapObjects) },
+		RuntimeGCTargetHeap: func(ms *runtime.MemStats) float64 { return float64(ms.NextGC) },
+	}
+	for name, fn := range gauges {
+		err = multierr.Append(err, m.Gauge(name, s.memGauge(fn)))
+	}
+	counters := map[string]func(ms *runtime.MemStats) float64{
+		RuntimeGCCount: func(ms *runtime.MemStats) float64 { return float64(ms.NumGC) },
+		RuntimeGCPauseTotal: func(ms *runtime.MemStats) float64 {
+			return float64(ms.PauseTotalNs) / float64(time.Millisecond)
+		},
+	}
+	for name, fn := range counters {
+		err = multierr.Append(err, m.ObservableCounter(name, s.memGauge(fn)))
+	}
+	err = multierr.Append(err, m.Gauge(RuntimeGoroutines, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		observe(float64(runtime.NumGoroutine()))
+	}))
+	err = multierr.Append(err, m.Gauge(ProcessUptime, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		observe(time.Since(processStart).Seconds())
+	}))
+	err = multierr.Append(err, m.Gauge(ProcessOpenFDs, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		if n, err := openFDs(); err == nil {
+			observe(float64(n))
+		}
+	}))
+	err = multierr.Append(err, m.ObservableCounter(ProcessCPUTime, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		if user, system, err := cpuTime(); err == nil {
+			observe(user.Seconds(), attribute.String("state", "user"))
+			observe(system.Seconds(), attribute.String("state", "system"))
+		}
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.run(interval)
+	return s, nil
+}
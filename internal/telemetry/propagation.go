@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultPropagators = "tracecontext,baggage"
+	// TraceResponseHeader tells the caller which trace and span served the
+	// request, in the traceparent format of the W3C Trace Context Level 2.
+	TraceResponseHeader = "traceresponse"
+)
+
+// TraceResponse formats sc as a traceresponse header value.
+func TraceResponse(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+}
+
+// ParseTraceResponse returns the trace ID of a traceresponse header value.
+func ParseTraceResponse(v string) string {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) != 4 {
+		return ""
+	}
+	id, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// NewPropagator builds the propagators listed in names, the caller service
+// header is always propagated unless names is "none".
+func NewPropagator(names string, b *Baggage, service string) (propagation.TextMapPropagator, error) {
+	if strings.TrimSpace(names) == "" {
+		names = defaultPropagators
+	}
+	props := []propagation.TextMapPropagator{callerPropagator{service: service}}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "tracecontext":
+			props = append(props, propagation.TraceContext{})
+		case "baggage":
+			props = append(props, baggagePropagator{b: b})
+		case "none":
+			return propagation.NewCompositeTextMapPropagator(), nil
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
@@ -0,0 +1,241 @@
+package telemetry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	RedactDrop = "drop"
+	RedactHash = "hash"
+	RedactMask = "mask"
+
+	redactedMask = "****"
+)
+
+// builtin value patterns usable by name in RedactValues
+var redactPatterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	"card":  regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+}
+
+type keyRule struct {
+	pattern string
+	action  string
+}
+
+type valueRule struct {
+	name   string
+	re     *regexp.Regexp
+	action string
+}
+
+// Redactor drops, hashes or masks attribute values before they leave the
+// process. Key rules match attribute keys with path.Match patterns and apply
+// to the whole value, value rules match string values with a regexp and apply
+// to the matched parts only ("drop" removes the attribute).
+type Redactor struct {
+	keys    []keyRule
+	values  []valueRule
+	hashKey []byte
+}
+
+func parseRedactRule(entry string) (string, string, error) {
+	i := strings.LastIndex(entry, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid redaction rule %q", entry)
+	}
+	pattern, action := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+	switch action {
+	case RedactDrop, RedactHash, RedactMask:
+		return pattern, action, nil
+	}
+	return "", "", fmt.Errorf("invalid redaction action %q in %q", action, entry)
+}
+
+// NewRedactor parses RedactKeys ("order.name=mask,customer.*=hash") and
+// RedactValues ("email=mask,card=drop"), a value pattern is either a builtin
+// name (email, card) or a regexp without commas. Hash rules need
+// RedactHashKey, a plain hash of a short value like a card number is
+// reversed by trying every candidate.
+func NewRedactor(c *config.Config) (*Redactor, error) {
+	r := &Redactor{hashKey: []byte(c.RedactHashKey)}
+	hashes := false
+	for _, entry := range splitList(c.RedactKeys) {
+		pattern, action, err := parseRedactRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid redaction key pattern %q: %w", pattern, err)
+		}
+		hashes = hashes || action == RedactHash
+		r.keys = append(r.keys, keyRule{pattern: pattern, action: action})
+	}
+	for _, entry := range splitList(c.RedactValues) {
+		pattern, action, err := parseRedactRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		re, ok := redactPatterns[pattern]
+		if !ok {
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid redaction value pattern %q: %w", pattern, err)
+			}
+		}
+		hashes = hashes || action == RedactHash
+		r.values = append(r.values, valueRule{name: pattern, re: re, action: action})
+	}
+	if hashes && len(r.hashKey) == 0 {
+		return nil, fmt.Errorf("hash redaction rules need a RedactHashKey")
+	}
+	return r, nil
+}
+
+func (r *Redactor) Enabled() bool {
+	return len(r.keys) > 0 || len(r.values) > 0
+}
+
+// hash keeps equal values correlatable without revealing them.
+func (r *Redactor) hash(v string) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(v))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// luhn filters out long numbers (timestamps, ids) matched by the card pattern.
+func luhn(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return sum%10 == 0
+}
+
+func (r *Redactor) redactString(s string) (string, bool) {
+	for _, rule := range r.values {
+		matched := false
+		s = rule.re.ReplaceAllStringFunc(s, func(m string) string {
+			if rule.name == "card" && !luhn(m) {
+				return m
+			}
+			matched = true
+			if rule.action == RedactHash {
+				return r.hash(m)
+			}
+			return redactedMask
+		})
+		if matched && rule.action == RedactDrop {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// Redact applies the rules to a single attribute, false means the attribute
+// has to be dropped.
+func (r *Redactor) Redact(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	for _, rule := range r.keys {
+		if ok, _ := path.Match(rule.pattern, string(kv.Key)); !ok {
+			continue
+		}
+		switch rule.action {
+		case RedactDrop:
+			return kv, false
+		case RedactHash:
+			return kv.Key.String(r.hash(kv.Value.Emit())), true
+		default:
+			return kv.Key.String(redactedMask), true
+		}
+	}
+	if len(r.values) == 0 {
+		return kv, true
+	}
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		s, ok := r.redactString(kv.Value.AsString())
+		return kv.Key.String(s), ok
+	case attribute.STRINGSLICE:
+		vals := kv.Value.AsStringSlice()
+		res := make([]string, 0, len(vals))
+		for _, v := range vals {
+			if s, ok := r.redactString(v); ok {
+				res = append(res, s)
+			}
+		}
+		return kv.Key.StringSlice(res), true
+	}
+	return kv, true
+}
+
+// Attributes returns attrs with the rules applied, attrs itself is not
+// modified.
+func (r *Redactor) Attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if !r.Enabled() || len(attrs) == 0 {
+		return attrs
+	}
+	res := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if kv, ok := r.Redact(kv); ok {
+			res = append(res, kv)
+		}
+	}
+	return res
+}
+
+// SpanProcessor wraps next so that it receives spans with redacted attributes
+// and events.
+func (r *Redactor) SpanProcessor(next tracesdk.SpanProcessor) tracesdk.SpanProcessor {
+	if !r.Enabled() {
+		return next
+	}
+	return redactSpanProcessor{SpanProcessor: next, r: r}
+}
+
+type redactedSpan struct {
+	tracesdk.ReadOnlySpan
+	r *Redactor
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.r.Attributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []tracesdk.Event {
+	events := s.ReadOnlySpan.Events()
+	res := make([]tracesdk.Event, len(events))
+	for i, e := range events {
+		e.Attributes = s.r.Attributes(e.Attributes)
+		res[i] = e
+	}
+	return res
+}
+
+type redactSpanProcessor struct {
+	tracesdk.SpanProcessor
+	r *Redactor
+}
+
+func (p redactSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(redactedSpan{ReadOnlySpan: s, r: p.r})
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := NewRedactor(&config.Config{
+		RedactKeys:    "order.name=mask,customer.*=hash,http.user_agent=drop",
+		RedactValues:  "email=mask,card=drop",
+		RedactHashKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[attribute.Key]string{}
+	for _, kv := range r.Attributes([]attribute.KeyValue{
+		attribute.String("order.name", "book"),
+		attribute.String("customer.id", "42"),
+		attribute.String("http.user_agent", "curl"),
+		attribute.String("message", "mail jane@example.com"),
+		attribute.String("payment", "card 4111 1111 1111 1111"),
+		attribute.String("timestamp", "1634567890123456"),
+		attribute.Int("amount", 10),
+	}) {
+		got[kv.Key] = kv.Value.Emit()
+	}
+	want := map[attribute.Key]string{
+		"order.name": redactedMask,
+		"message":    "mail " + redactedMask,
+		"timestamp":  "1634567890123456",
+		"amount":     "10",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if !strings.HasPrefix(got["customer.id"], "hmac:") {
+		t.Errorf("customer.id = %q, want a hash", got["customer.id"])
+	}
+	for _, k := range []attribute.Key{"http.user_agent", "payment"} {
+		if v, ok := got[k]; ok {
+			t.Errorf("%s = %q, want it dropped", k, v)
+		}
+	}
+}
+
+func TestRedactorRejectsBadRules(t *testing.T) {
+	for _, c := range []config.Config{
+		{RedactKeys: "order.name"},
+		{RedactKeys: "order.name=encrypt"},
+		{RedactKeys: "[=mask"},
+		{RedactValues: "(=mask"},
+		{RedactKeys: "customer.*=hash"},
+	} {
+		if _, err := NewRedactor(&c); err == nil {
+			t.Errorf("NewRedactor(%+v) accepted a bad rule", c)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"runtime/debug"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	uuid "github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+var (
+	containerIDRe = regexp.MustCompile(`([0-9a-f]{64})`)
+	// mountinfo also lists overlayfs image layer digests, only the per
+	// container paths of docker and containerd/CRI-O sandboxes are trusted.
+	mountinfoContainerIDRe = regexp.MustCompile(`/(?:docker/containers|sandboxes)/([0-9a-f]{64})/`)
+)
+
+type containerDetector struct{}
+
+func containerIDFromFile(path string, re *regexp.Regexp) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if m := re.FindStringSubmatch(s.Text()); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func (containerDetector) Detect(context.Context) (*resource.Resource, error) {
+	id := containerIDFromFile("/proc/self/cgroup", containerIDRe)
+	if id == "" {
+		id = containerIDFromFile("/proc/self/mountinfo", mountinfoContainerIDRe)
+	}
+	if id == "" {
+		return resource.Empty(), nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, semconv.ContainerIDKey.String(id)), nil
+}
+
+func serviceVersion(c *config.Config) string {
+	if c.ServiceVersion != "" {
+		return c.ServiceVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+func NewResource(ctx context.Context, c *config.Config, service string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(service),
+		semconv.ServiceVersionKey.String(serviceVersion(c)),
+		semconv.ServiceInstanceIDKey.String(uuid.NewV4().String()),
+	}
+	if c.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(c.Environment))
+	}
+	return resource.New(
+		ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(attrs...),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOSType(),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessOwner(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithProcessRuntimeDescription(),
+		resource.WithDetectors(containerDetector{}),
+		resource.WithFromEnv(),
+	)
+}
@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainerIDFromMountinfo(t *testing.T) {
+	id := strings.Repeat("ab12", 16)
+	layer := strings.Repeat("cd34", 16)
+	for name, tc := range map[string]struct {
+		mountinfo string
+		want      string
+	}{
+		"docker": {
+			mountinfo: "1 0 0:1 / / rw - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/" + layer + "/diff\n" +
+				"2 1 8:1 /var/lib/docker/containers/" + id + "/resolv.conf /etc/resolv.conf rw - ext4 /dev/sda1 rw\n",
+			want: id,
+		},
+		"containerd": {
+			mountinfo: "2 1 8:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/" + id + "/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n",
+			want:      id,
+		},
+		"overlay layers only": {
+			mountinfo: "1 0 0:1 / / rw - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/" + layer + "/diff\n",
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "mountinfo")
+		if err := os.WriteFile(path, []byte(tc.mountinfo), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got := containerIDFromFile(path, mountinfoContainerIDRe); got != tc.want {
+			t.Errorf("%s: container id = %q, want %q", name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SamplerAlways                 = "always_on"
+	SamplerNever                  = "always_off"
+	SamplerRatio                  = "traceidratio"
+	SamplerRateLimited            = "ratelimited"
+	SamplerParentBasedAlways      = "parentbased_always_on"
+	SamplerParentBasedNever       = "parentbased_always_off"
+	SamplerParentBasedRatio       = "parentbased_traceidratio"
+	SamplerParentBasedRateLimited = "parentbased_ratelimited"
+)
+
+type SamplingConfig struct {
+	Sampler string `json:"sampler"`
+	// Arg is the ratio for traceidratio samplers and spans per second for
+	// ratelimited samplers.
+	Arg          float64           `json:"arg"`
+	Routes       map[string]string `json:"routes,omitempty"`
+	SampleErrors bool              `json:"sample_errors"`
+	// Tail records the spans that would be dropped for the tail sampling
+	// processor, which makes the final decision. It follows the processor
+	// built at startup, so updates can't change it.
+	Tail bool `json:"tail"`
+	// SpanMetrics records every span, also the ones of never routes, so the
+	// span metrics processor counts all of them and not just the sampled ones.
+	// Like Tail, it is fixed at startup.
+	SpanMetrics bool `json:"span_metrics"`
+}
+
+func parseSamplingRoutes(raw string) (map[string]string, error) {
+	routes := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid sampling route %q", entry)
+		}
+		routes[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return routes, nil
+}
+
+func NewSamplingConfig(c *config.Config) (SamplingConfig, error) {
+	cfg := SamplingConfig{Sampler: c.TraceSampler, Arg: 1, SampleErrors: c.TraceSampleErrors, Tail: c.TailSamplingPolicies != "", SpanMetrics: c.SpanMetrics}
+	if cfg.Sampler == "" {
+		cfg.Sampler = SamplerParentBasedAlways
+	}
+	if c.TraceSamplerArg != "" {
+		arg, err := strconv.ParseFloat(c.TraceSamplerArg, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid sampler argument: %w", err)
+		}
+		cfg.Arg = arg
+	}
+	routes, err := parseSamplingRoutes(c.TraceSamplerRoutes)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Routes = routes
+	return cfg, nil
+}
+
+func (cfg SamplingConfig) build() (tracesdk.Sampler, error) {
+	switch strings.ToLower(cfg.Sampler) {
+	case SamplerRatio, SamplerParentBasedRatio:
+		if cfg.Arg < 0 || cfg.Arg > 1 {
+			return nil, fmt.Errorf("invalid sampling ratio %g, want 0 to 1", cfg.Arg)
+		}
+	case SamplerRateLimited, SamplerParentBasedRateLimited:
+		if cfg.Arg <= 0 {
+			return nil, fmt.Errorf("invalid sampling rate %g, want spans per second above 0", cfg.Arg)
+		}
+	}
+	var s tracesdk.Sampler
+	switch strings.ToLower(cfg.Sampler) {
+	case SamplerAlways:
+		s = tracesdk.AlwaysSample()
+	case SamplerNever:
+		s = tracesdk.NeverSample()
+	case SamplerRatio:
+		s = tracesdk.TraceIDRatioBased(cfg.Arg)
+	case SamplerRateLimited:
+		s = newRateLimitedSampler(cfg.Arg)
+	case SamplerParentBasedAlways:
+		s = tracesdk.ParentBased(tracesdk.AlwaysSample())
+	case SamplerParentBasedNever:
+		s = tracesdk.ParentBased(tracesdk.NeverSample())
+	case SamplerParentBasedRatio:
+		s = tracesdk.ParentBased(tracesdk.TraceIDRatioBased(cfg.Arg))
+	case SamplerParentBasedRateLimited:
+		s = tracesdk.ParentBased(newRateLimitedSampler(cfg.Arg))
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", cfg.Sampler)
+	}
+
+	rs := &routeSampler{next: s, sampleErrors: cfg.SampleErrors, tail: cfg.Tail, spanMetrics: cfg.SpanMetrics}
+	for route, decision := range cfg.Routes {
+		var d tracesdk.Sampler
+		switch strings.ToLower(decision) {
+		case "always":
+			d = tracesdk.AlwaysSample()
+		case "never":
+			d = tracesdk.NeverSample()
+		default:
+			return nil, fmt.Errorf("unsupported sampling decision %q for route %q", decision, route)
+		}
+		rs.rules = append(rs.rules, routeRule{pattern: route, sampler: d})
+	}
+	sort.Slice(rs.rules, func(i, j int) bool { return len(rs.rules[i].pattern) > len(rs.rules[j].pattern) })
+	return rs, nil
+}
+
+type rateLimitedSampler struct {
+	mu       sync.Mutex
+	rate     float64
+	balance  float64
+	lastTick time.Time
+}
+
+func newRateLimitedSampler(perSecond float64) *rateLimitedSampler {
+	return &rateLimitedSampler{rate: perSecond, balance: math.Max(perSecond, 1), lastTick: time.Now()}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.balance += now.Sub(s.lastTick).Seconds() * s.rate
+	// a span costs 1, so rates below 1/s still need room for one
+	if max := math.Max(s.rate, 1); s.balance > max {
+		s.balance = max
+	}
+	s.lastTick = now
+	res := tracesdk.SamplingResult{Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+	if s.balance >= 1 {
+		s.balance--
+		res.Decision = tracesdk.RecordAndSample
+	}
+	return res
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimited{%g}", s.rate)
+}
+
+type routeRule struct {
+	pattern string
+	sampler tracesdk.Sampler
+}
+
+func (r routeRule) matches(value string) bool {
+	if strings.HasSuffix(r.pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(r.pattern, "*"))
+	}
+	return value == r.pattern
+}
+
+// routeSampler applies per-route overrides by span name or http.route/http.target
+// attributes. With sampleErrors enabled spans that would be dropped are still
+// recorded, at the full recording cost, so errorSpanProcessor can export them
+// if they end with an error, with tail enabled so tailSpanProcessor can decide
+// on them and with spanMetrics enabled, for all spans, so the span metrics
+// aren't biased by sampling. The propagated flags stay unsampled either way.
+type routeSampler struct {
+	rules        []routeRule
+	next         tracesdk.Sampler
+	sampleErrors bool
+	tail         bool
+	spanMetrics  bool
+}
+
+func (s *routeSampler) match(p tracesdk.SamplingParameters) tracesdk.Sampler {
+	candidates := []string{p.Name}
+	for _, a := range p.Attributes {
+		if a.Key == semconv.HTTPRouteKey || a.Key == semconv.HTTPTargetKey {
+			candidates = append(candidates, a.Value.AsString())
+		}
+	}
+	for _, r := range s.rules {
+		for _, c := range candidates {
+			if r.matches(c) {
+				return r.sampler
+			}
+		}
+	}
+	return nil
+}
+
+func (s *routeSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	override := s.match(p)
+	if override != nil {
+		res := override.ShouldSample(p)
+		if s.spanMetrics && res.Decision == tracesdk.Drop {
+			res.Decision = tracesdk.RecordOnly
+		}
+		return res
+	}
+	res := s.next.ShouldSample(p)
+	if (s.sampleErrors || s.tail || s.spanMetrics) && res.Decision == tracesdk.Drop {
+		res.Decision = tracesdk.RecordOnly
+	}
+	return res
+}
+
+func (s *routeSampler) Description() string {
+	return fmt.Sprintf("Routes{rules:%d,errors:%t,tail:%t,spanMetrics:%t,%s}", len(s.rules), s.sampleErrors, s.tail, s.spanMetrics, s.next.Description())
+}
+
+type Sampler struct {
+	mu      sync.Mutex
+	cfg     SamplingConfig
+	sampler atomic.Value
+}
+
+func NewSampler(cfg SamplingConfig) (*Sampler, error) {
+	s := &Sampler{}
+	if err := s.Update(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Update replaces the sampling config, Tail and SpanMetrics keep the values
+// the sampler was created with.
+func (s *Sampler) Update(cfg SamplingConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sampler.Load() != nil {
+		cfg.Tail, cfg.SpanMetrics = s.cfg.Tail, s.cfg.SpanMetrics
+	}
+	built, err := cfg.build()
+	if err != nil {
+		return err
+	}
+	s.cfg = cfg
+	s.sampler.Store(built)
+	return nil
+}
+
+func (s *Sampler) Config() SamplingConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+func (s *Sampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	return s.sampler.Load().(tracesdk.Sampler).ShouldSample(p)
+}
+
+func (s *Sampler) Description() string {
+	return s.sampler.Load().(tracesdk.Sampler).Description()
+}
+
+func (s *Sampler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		cfg := s.Config()
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.Update(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Config())
+}
+
+type sampledSpan struct {
+	tracesdk.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
+
+// errorSpanProcessor forwards recorded but unsampled spans that ended with an
+// error status to the wrapped processor as sampled ones while SampleErrors is
+// on, spans can also be recorded for span metrics only. This is local error
+// capture, not a sampling decision: callers and downstream services already
+// saw the unsampled flags, so the exported spans usually miss their parent and
+// children.
+type errorSpanProcessor struct {
+	tracesdk.SpanProcessor
+	sampler *Sampler
+}
+
+func (p errorSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() && s.Status().Code == codes.Error && p.sampler.Config().SampleErrors {
+		s = sampledSpan{s}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
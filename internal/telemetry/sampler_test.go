@@ -0,0 +1,161 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRateLimitedSamplerFractionalRate(t *testing.T) {
+	s := newRateLimitedSampler(0.2)
+	p := tracesdk.SamplingParameters{Name: "span"}
+	if d := s.ShouldSample(p).Decision; d != tracesdk.RecordAndSample {
+		t.Fatalf("first span decision = %v, want RecordAndSample", d)
+	}
+	if d := s.ShouldSample(p).Decision; d != tracesdk.Drop {
+		t.Fatalf("second span decision = %v, want Drop", d)
+	}
+	// 5s at 0.2/s earn one span, even 10s must not earn more than one
+	s.lastTick = time.Now().Add(-10 * time.Second)
+	if d := s.ShouldSample(p).Decision; d != tracesdk.RecordAndSample {
+		t.Fatalf("decision after 10s = %v, want RecordAndSample", d)
+	}
+	if d := s.ShouldSample(p).Decision; d != tracesdk.Drop {
+		t.Fatalf("decision after the refill = %v, want Drop", d)
+	}
+}
+
+func TestRateLimitedSampler(t *testing.T) {
+	s := newRateLimitedSampler(3)
+	p := tracesdk.SamplingParameters{Name: "span"}
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if s.ShouldSample(p).Decision == tracesdk.RecordAndSample {
+			sampled++
+		}
+	}
+	if sampled != 3 {
+		t.Fatalf("sampled %d spans, want 3", sampled)
+	}
+}
+
+func TestSamplerRejectsBadArg(t *testing.T) {
+	for _, cfg := range []SamplingConfig{
+		{Sampler: SamplerRateLimited, Arg: 0},
+		{Sampler: SamplerParentBasedRateLimited, Arg: -1},
+		{Sampler: SamplerRatio, Arg: 1.5},
+		{Sampler: SamplerParentBasedRatio, Arg: -0.1},
+	} {
+		if _, err := NewSampler(cfg); err == nil {
+			t.Errorf("NewSampler(%s, %g) accepted a bad argument", cfg.Sampler, cfg.Arg)
+		}
+	}
+	s, err := NewSampler(SamplingConfig{Sampler: SamplerRateLimited, Arg: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(SamplingConfig{Sampler: SamplerRateLimited, Arg: -5}); err == nil {
+		t.Error("Update accepted a negative rate")
+	}
+	if got := s.Config().Arg; got != 1 {
+		t.Errorf("Arg = %g after a rejected update, want 1", got)
+	}
+}
+
+func TestSamplerUpdateKeepsProcessorFlags(t *testing.T) {
+	s, err := NewSampler(SamplingConfig{Sampler: SamplerNever, Tail: true, SpanMetrics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/sampling", strings.NewReader(`{"tail":false,"span_metrics":false}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cfg := s.Config(); !cfg.Tail || !cfg.SpanMetrics {
+		t.Errorf("tail = %t, span_metrics = %t after the update, want both kept on", cfg.Tail, cfg.SpanMetrics)
+	}
+	// the processors built at startup still get the unsampled spans
+	if got := s.ShouldSample(tracesdk.SamplingParameters{Name: "span"}).Decision; got != tracesdk.RecordOnly {
+		t.Errorf("decision = %v, want RecordOnly", got)
+	}
+}
+
+func TestSamplerRoutes(t *testing.T) {
+	s, err := NewSampler(SamplingConfig{
+		Sampler: SamplerNever,
+		Routes:  map[string]string{"/order*": "always", "/order/health": "never"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]tracesdk.SamplingDecision{
+		"/order/1":      tracesdk.RecordAndSample,
+		"/order/health": tracesdk.Drop,
+		"/payment":      tracesdk.Drop,
+	} {
+		if got := s.ShouldSample(tracesdk.SamplingParameters{Name: name}).Decision; got != want {
+			t.Errorf("%s: decision = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSamplerRecordsDroppedSpansForErrors(t *testing.T) {
+	s, err := NewSampler(SamplingConfig{Sampler: SamplerNever, SampleErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.ShouldSample(tracesdk.SamplingParameters{Name: "span"}).Decision; got != tracesdk.RecordOnly {
+		t.Fatalf("decision = %v, want RecordOnly", got)
+	}
+}
+
+func TestSamplerRecordsAllSpansForSpanMetrics(t *testing.T) {
+	s, err := NewSampler(SamplingConfig{
+		Sampler:     SamplerNever,
+		Routes:      map[string]string{"/health": "never"},
+		SpanMetrics: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"span", "/health"} {
+		if got := s.ShouldSample(tracesdk.SamplingParameters{Name: name}).Decision; got != tracesdk.RecordOnly {
+			t.Errorf("%s: decision = %v, want RecordOnly", name, got)
+		}
+	}
+}
+
+func TestErrorSpanProcessor(t *testing.T) {
+	for _, sampleErrors := range []bool{false, true} {
+		s, err := NewSampler(SamplingConfig{Sampler: SamplerNever, SampleErrors: sampleErrors, SpanMetrics: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := tracetest.NewSpanRecorder()
+		tp := tracesdk.NewTracerProvider(
+			tracesdk.WithSampler(s),
+			tracesdk.WithSpanProcessor(errorSpanProcessor{SpanProcessor: rec, sampler: s}),
+		)
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+
+		sampled := 0
+		for _, s := range rec.Ended() {
+			if s.SpanContext().IsSampled() {
+				sampled++
+			}
+		}
+		if want := map[bool]int{false: 0, true: 1}[sampleErrors]; sampled != want {
+			t.Errorf("SampleErrors %t: exported %d error spans, want %d", sampleErrors, sampled, want)
+		}
+	}
+}
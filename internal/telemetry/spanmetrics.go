@@ -0,0 +1,187 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// callerHeader carries the service name of the caller, so the callee can
+	// tell who is on the other side of an edge.
+	callerHeader = "x-caller-service"
+	// maxCallerLength bounds the header value taken from the wire.
+	maxCallerLength = 64
+
+	defaultSpanMetricsMaxSeries = 1000
+	// overflowValue replaces dimension values once the series cap is hit.
+	overflowValue = "_other"
+)
+
+type callerKey struct{}
+
+// callerFromContext returns the caller service extracted from the request.
+func callerFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(callerKey{}).(string)
+	return s
+}
+
+type callerPropagator struct {
+	service string
+}
+
+func (p callerPropagator) Inject(_ context.Context, carrier propagation.TextMapCarrier) {
+	if p.service != "" {
+		carrier.Set(callerHeader, p.service)
+	}
+}
+
+func (p callerPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	v := strings.TrimSpace(carrier.Get(callerHeader))
+	if v == "" {
+		return ctx
+	}
+	if len(v) > maxCallerLength {
+		v = v[:maxCallerLength]
+	}
+	return context.WithValue(ctx, callerKey{}, v)
+}
+
+func (callerPropagator) Fields() []string { return []string{callerHeader} }
+
+// seriesLimiter caps the number of distinct dimension sets of a metric.
+type seriesLimiter struct {
+	mu     sync.Mutex
+	max    int
+	series map[string]struct{}
+}
+
+func newSeriesLimiter(max int) *seriesLimiter {
+	return &seriesLimiter{max: max, series: map[string]struct{}{}}
+}
+
+// allow reports whether key is a known series or there is still room for it.
+func (l *seriesLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.series[key]; ok {
+		return true
+	}
+	if len(l.series) >= l.max {
+		return false
+	}
+	l.series[key] = struct{}{}
+	return true
+}
+
+// spanMetricsProcessor derives RED metrics per operation and span kind and
+// caller -> callee edge metrics from finished spans. Edges are recorded by the
+// callee from its server and consumer spans, the caller is known from the
+// x-caller-service header set by callerPropagator.
+type spanMetricsProcessor struct {
+	service      string
+	calls        meter.Counter
+	errors       meter.Counter
+	duration     meter.Histogram
+	edges        meter.Counter
+	edgeFailures meter.Counter
+	edgeDuration meter.Histogram
+	spanSeries   *seriesLimiter
+	edgeSeries   *seriesLimiter
+}
+
+func newSpanMetricsProcessor(c *config.Config, service string, m meter.Meter) *spanMetricsProcessor {
+	max := c.SpanMetricsMaxSeries
+	if max <= 0 {
+		max = defaultSpanMetricsMaxSeries
+	}
+	return &spanMetricsProcessor{
+		service:      service,
+		calls:        m.Counter(meter.SpanCalls),
+		errors:       m.Counter(meter.SpanErrors),
+		duration:     m.Histogram(meter.SpanDuration),
+		edges:        m.Counter(meter.ServiceGraphRequests),
+		edgeFailures: m.Counter(meter.ServiceGraphFailedRequests),
+		edgeDuration: m.Histogram(meter.ServiceGraphDuration),
+		spanSeries:   newSeriesLimiter(max),
+		edgeSeries:   newSeriesLimiter(max),
+	}
+}
+
+func isCallee(kind trace.SpanKind) bool {
+	return kind == trace.SpanKindServer || kind == trace.SpanKindConsumer
+}
+
+func (p *spanMetricsProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	if !isCallee(s.SpanKind()) {
+		return
+	}
+	if caller := callerFromContext(parent); caller != "" {
+		s.SetAttributes(semconv.PeerServiceKey.String(caller))
+	}
+}
+
+func (p *spanMetricsProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	// the span context makes the duration exemplars point at the span
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	ms := float64(s.EndTime().Sub(s.StartTime())) / 1e6
+	kind := s.SpanKind()
+	failed := s.Status().Code == codes.Error
+
+	name := s.Name()
+	if !p.spanSeries.allow(name + "\xff" + kind.String()) {
+		name = overflowValue
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("span.name", name),
+		attribute.String("span.kind", kind.String()),
+	}
+	p.duration.Record(ctx, ms, attrs...)
+	p.calls.Add(ctx, 1, append(attrs, attribute.String("status.code", s.Status().Code.String()))...)
+	if failed {
+		p.errors.Add(ctx, 1, attrs...)
+	}
+
+	if !isCallee(kind) {
+		return
+	}
+	caller := ""
+	for _, kv := range s.Attributes() {
+		if kv.Key == semconv.PeerServiceKey {
+			caller = kv.Value.AsString()
+			break
+		}
+	}
+	if caller == "" {
+		return
+	}
+	connection := "request"
+	if kind == trace.SpanKindConsumer {
+		connection = "messaging"
+	}
+	if !p.edgeSeries.allow(caller + "\xff" + connection) {
+		caller = overflowValue
+	}
+	edge := []attribute.KeyValue{
+		attribute.String("client", caller),
+		attribute.String("server", p.service),
+		attribute.String("connection_type", connection),
+	}
+	p.edges.Add(ctx, 1, edge...)
+	p.edgeDuration.Record(ctx, ms, edge...)
+	if failed {
+		p.edgeFailures.Add(ctx, 1, edge...)
+	}
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error   { return nil }
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error { return nil }
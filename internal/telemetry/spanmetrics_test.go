@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+func TestSeriesLimiter(t *testing.T) {
+	l := newSeriesLimiter(2)
+	for _, key := range []string{"a", "b", "a"} {
+		if !l.allow(key) {
+			t.Fatalf("allow(%q) = false within the cap", key)
+		}
+	}
+	if l.allow("c") {
+		t.Fatal("allow(c) = true beyond the cap")
+	}
+	if !l.allow("b") {
+		t.Fatal("allow(b) = false for a known series")
+	}
+}
+
+func TestSpanMetricsOverflow(t *testing.T) {
+	m, ctrl := meter.NewManualMeter(zap.NewNop(), resource.Empty())
+	p := newSpanMetricsProcessor(&config.Config{SpanMetricsMaxSeries: 2}, "order", m)
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(p))
+	for i := 0; i < 5; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), fmt.Sprintf("op-%d", i))
+		span.End()
+	}
+	if err := ctrl.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	err := ctrl.ForEach(export.CumulativeExportKindSelector(), func(r export.Record) error {
+		if r.Descriptor().Name() == meter.SpanCalls {
+			v, _ := r.Labels().Value("span.name")
+			names[v.AsString()] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || !names["op-0"] || !names["op-1"] || !names[overflowValue] {
+		t.Fatalf("span.name values %v, want op-0, op-1 and %s", names, overflowValue)
+	}
+}
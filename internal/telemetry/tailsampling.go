@@ -0,0 +1,336 @@
+package telemetry
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultTailWindow    = 5 * time.Second
+	defaultTailMaxTraces = 10000
+	defaultTailMaxSpans  = 100000
+)
+
+type tailTrace struct {
+	id       trace.TraceID
+	spans    []tracesdk.ReadOnlySpan
+	start    time.Time
+	end      time.Time
+	hasError bool
+	// rootEnded is when the local root span ended, zero while it runs
+	rootEnded time.Time
+	// arrival and due are the elements of the trace in the processor lists
+	arrival *list.Element
+	due     *list.Element
+	// policy is the policy that kept the trace, empty if dropped
+	policy string
+}
+
+func (t *tailTrace) add(s tracesdk.ReadOnlySpan) {
+	t.spans = append(t.spans, s)
+	if t.start.IsZero() || s.StartTime().Before(t.start) {
+		t.start = s.StartTime()
+	}
+	if s.EndTime().After(t.end) {
+		t.end = s.EndTime()
+	}
+	if s.Status().Code == codes.Error {
+		t.hasError = true
+	}
+}
+
+// tailDecision is remembered for the spans of a trace which end after it.
+type tailDecision struct {
+	id   trace.TraceID
+	at   time.Time
+	keep bool
+}
+
+type tailPolicy struct {
+	name  string
+	match func(t *tailTrace) bool
+}
+
+// parseTailPolicies parses TailSamplingPolicies, e.g.
+// "error,latency=500ms,attribute=order.status:failed,probabilistic=0.1".
+func parseTailPolicies(raw string) ([]tailPolicy, error) {
+	var policies []tailPolicy
+	for _, entry := range splitList(raw) {
+		name, arg := entry, ""
+		if i := strings.Index(entry, "="); i >= 0 {
+			name, arg = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		switch name {
+		case "error":
+			policies = append(policies, tailPolicy{name: name, match: func(t *tailTrace) bool { return t.hasError }})
+		case "latency":
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tail sampling latency %q: %w", arg, err)
+			}
+			policies = append(policies, tailPolicy{name: name, match: func(t *tailTrace) bool { return t.end.Sub(t.start) >= d }})
+		case "attribute":
+			kv := strings.SplitN(arg, ":", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("invalid tail sampling attribute %q, want key:value", arg)
+			}
+			key, value := attribute.Key(kv[0]), kv[1]
+			policies = append(policies, tailPolicy{name: name, match: func(t *tailTrace) bool {
+				for _, s := range t.spans {
+					for _, a := range s.Attributes() {
+						if a.Key == key && a.Value.Emit() == value {
+							return true
+						}
+					}
+				}
+				return false
+			}})
+		case "probabilistic":
+			ratio, err := strconv.ParseFloat(arg, 64)
+			if err != nil || ratio < 0 || ratio > 1 {
+				return nil, fmt.Errorf("invalid tail sampling ratio %q", arg)
+			}
+			// same bound as TraceIDRatioBased, so services keep the same traces
+			bound := uint64(ratio * (1 << 63))
+			policies = append(policies, tailPolicy{name: name, match: func(t *tailTrace) bool {
+				return binary.BigEndian.Uint64(t.id[0:8])>>1 < bound
+			}})
+		default:
+			return nil, fmt.Errorf("unsupported tail sampling policy %q", entry)
+		}
+	}
+	return policies, nil
+}
+
+// tailSpanProcessor buffers the spans of every local trace until a window
+// after its local root span ended, then forwards the whole trace to the export
+// processors if any policy matches and drops it otherwise. Spans of a trace
+// which end after the decision follow it. When the trace or span limit is hit
+// the oldest traces are decided early, nothing is dropped undecided.
+type tailSpanProcessor struct {
+	next      []tracesdk.SpanProcessor
+	policies  []tailPolicy
+	window    time.Duration
+	maxTraces int
+	maxSpans  int
+	decisions meter.Counter
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*tailTrace
+	// arrivals holds the buffered traces by first span, dues the ones whose
+	// root ended by root end, so both are in the order they are decided in.
+	arrivals *list.List
+	dues     *list.List
+	spans    int
+	// decided holds the last decisions, at most maxTraces, oldest first
+	decided map[trace.TraceID]*list.Element
+	recent  *list.List
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTailSpanProcessor(c *config.Config, next []tracesdk.SpanProcessor, m meter.Meter) (*tailSpanProcessor, error) {
+	policies, err := parseTailPolicies(c.TailSamplingPolicies)
+	if err != nil {
+		return nil, err
+	}
+	p := &tailSpanProcessor{
+		next:      next,
+		policies:  policies,
+		window:    defaultTailWindow,
+		maxTraces: c.TailSamplingMaxTraces,
+		maxSpans:  c.TailSamplingMaxSpans,
+		decisions: m.Counter(meter.TailSamplingTraces),
+		traces:    map[trace.TraceID]*tailTrace{},
+		arrivals:  list.New(),
+		dues:      list.New(),
+		decided:   map[trace.TraceID]*list.Element{},
+		recent:    list.New(),
+		done:      make(chan struct{}),
+	}
+	if c.TailSamplingWindow != "" {
+		if p.window, err = time.ParseDuration(c.TailSamplingWindow); err != nil || p.window <= 0 {
+			return nil, fmt.Errorf("invalid tail sampling window %q", c.TailSamplingWindow)
+		}
+	}
+	if p.maxTraces <= 0 {
+		p.maxTraces = defaultTailMaxTraces
+	}
+	if p.maxSpans <= 0 {
+		p.maxSpans = defaultTailMaxSpans
+	}
+	err = m.Gauge(meter.TailSamplingBufferedSpans, func(_ context.Context, observe func(float64, ...attribute.KeyValue)) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		observe(float64(p.spans))
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p, nil
+}
+
+func (p *tailSpanProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	for _, n := range p.next {
+		n.OnStart(parent, s)
+	}
+}
+
+func (p *tailSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	id := s.SpanContext().TraceID()
+	p.mu.Lock()
+	if e, ok := p.decided[id]; ok {
+		keep := e.Value.(*tailDecision).keep
+		p.mu.Unlock()
+		if keep {
+			p.forward([]tracesdk.ReadOnlySpan{s})
+		}
+		return
+	}
+	t, ok := p.traces[id]
+	if !ok {
+		t = &tailTrace{id: id}
+		t.arrival = p.arrivals.PushBack(t)
+		p.traces[id] = t
+	}
+	t.add(s)
+	p.spans++
+	if parent := s.Parent(); (!parent.IsValid() || parent.IsRemote()) && t.due == nil {
+		t.rootEnded = time.Now()
+		t.due = p.dues.PushBack(t)
+	}
+	var ready []*tailTrace
+	for p.arrivals.Len() > 0 && (len(p.traces) > p.maxTraces || p.spans > p.maxSpans) {
+		ready = append(ready, p.take(p.arrivals.Front().Value.(*tailTrace)))
+	}
+	p.mu.Unlock()
+	p.decide(ready)
+}
+
+// take removes the trace from the buffer and decides on it, p.mu must be held
+// so spans arriving meanwhile already see the decision.
+func (p *tailSpanProcessor) take(t *tailTrace) *tailTrace {
+	delete(p.traces, t.id)
+	p.arrivals.Remove(t.arrival)
+	if t.due != nil {
+		p.dues.Remove(t.due)
+	}
+	p.spans -= len(t.spans)
+	for _, pol := range p.policies {
+		if pol.match(t) {
+			t.policy = pol.name
+			break
+		}
+	}
+	p.decided[t.id] = p.recent.PushBack(&tailDecision{id: t.id, at: time.Now(), keep: t.policy != ""})
+	for p.recent.Len() > p.maxTraces {
+		p.forget(p.recent.Front())
+	}
+	return t
+}
+
+func (p *tailSpanProcessor) forget(e *list.Element) {
+	delete(p.decided, p.recent.Remove(e).(*tailDecision).id)
+}
+
+// decide forwards the kept traces.
+func (p *tailSpanProcessor) decide(traces []*tailTrace) {
+	for _, t := range traces {
+		keep := t.policy != ""
+		p.decisions.Add(context.Background(), 1,
+			attribute.Bool("sampled", keep), attribute.String("policy", t.policy))
+		if keep {
+			p.forward(t.spans)
+		}
+	}
+}
+
+func (p *tailSpanProcessor) forward(spans []tracesdk.ReadOnlySpan) {
+	for _, s := range spans {
+		if !s.SpanContext().IsSampled() {
+			s = sampledSpan{s}
+		}
+		for _, n := range p.next {
+			n.OnEnd(s)
+		}
+	}
+}
+
+// expire takes the traces whose root ended a window ago and forgets old
+// decisions.
+func (p *tailSpanProcessor) expire(now time.Time) []*tailTrace {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ready []*tailTrace
+	for p.dues.Len() > 0 {
+		t := p.dues.Front().Value.(*tailTrace)
+		if now.Sub(t.rootEnded) < p.window {
+			break
+		}
+		ready = append(ready, p.take(t))
+	}
+	for p.recent.Len() > 0 && now.Sub(p.recent.Front().Value.(*tailDecision).at) >= p.window {
+		p.forget(p.recent.Front())
+	}
+	return ready
+}
+
+func (p *tailSpanProcessor) run() {
+	defer p.wg.Done()
+	t := time.NewTicker(p.window / 4)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			p.decide(p.expire(now))
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// flush decides all buffered traces now.
+func (p *tailSpanProcessor) flush() {
+	p.mu.Lock()
+	var ready []*tailTrace
+	for p.arrivals.Len() > 0 {
+		ready = append(ready, p.take(p.arrivals.Front().Value.(*tailTrace)))
+	}
+	p.mu.Unlock()
+	p.decide(ready)
+}
+
+func (p *tailSpanProcessor) ForceFlush(ctx context.Context) (err error) {
+	p.flush()
+	for _, n := range p.next {
+		err = multierr.Append(err, n.ForceFlush(ctx))
+	}
+	return err
+}
+
+func (p *tailSpanProcessor) Shutdown(ctx context.Context) (err error) {
+	close(p.done)
+	p.wg.Wait()
+	p.flush()
+	for _, n := range p.next {
+		err = multierr.Append(err, n.Shutdown(ctx))
+	}
+	return err
+}
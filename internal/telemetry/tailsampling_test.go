@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func newTestTailSampler(t *testing.T, c *config.Config) (*tailSpanProcessor, *tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	m, _ := meter.NewManualMeter(zap.NewNop(), resource.Empty())
+	rec := tracetest.NewSpanRecorder()
+	p, err := newTailSpanProcessor(c, []tracesdk.SpanProcessor{rec}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Shutdown(context.Background()) })
+	// the spans are recorded only, like routeSampler does with tail sampling
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(&routeSampler{next: tracesdk.NeverSample(), tail: true}),
+		tracesdk.WithSpanProcessor(p),
+	)
+	return p, rec, tp.Tracer("test")
+}
+
+func endTrace(tracer trace.Tracer, name string, failed bool) trace.SpanContext {
+	ctx, root := tracer.Start(context.Background(), name)
+	_, child := tracer.Start(ctx, name+"-child")
+	if failed {
+		child.SetStatus(codes.Error, "failed")
+	}
+	child.End()
+	root.End()
+	return root.SpanContext()
+}
+
+func TestTailSamplingErrorPolicy(t *testing.T) {
+	p, rec, tracer := newTestTailSampler(t, &config.Config{TailSamplingPolicies: "error"})
+	endTrace(tracer, "ok", false)
+	failed := endTrace(tracer, "failed", true)
+	p.flush()
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want the 2 of the failed trace", len(spans))
+	}
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != failed.TraceID() || !s.SpanContext().IsSampled() {
+			t.Errorf("exported span %q of trace %s, sampled %t", s.Name(), s.SpanContext().TraceID(), s.SpanContext().IsSampled())
+		}
+	}
+}
+
+func TestTailSamplingMaxTraces(t *testing.T) {
+	p, rec, tracer := newTestTailSampler(t, &config.Config{TailSamplingPolicies: "error", TailSamplingMaxTraces: 1})
+	endTrace(tracer, "first", true)
+	endTrace(tracer, "second", false)
+
+	// the second trace pushed the first one out of the buffer
+	if n := len(rec.Ended()); n != 2 {
+		t.Fatalf("exported %d spans before the window ended, want 2", n)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.traces) != 1 || len(p.decided) > p.maxTraces {
+		t.Fatalf("buffered %d traces and %d decisions, want 1 and at most %d", len(p.traces), len(p.decided), p.maxTraces)
+	}
+}
+
+func TestTailSamplingWaitsForRoot(t *testing.T) {
+	p, rec, tracer := newTestTailSampler(t, &config.Config{TailSamplingPolicies: "error", TailSamplingWindow: "1s"})
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+
+	// the root is still running, however long ago the child ended
+	p.decide(p.expire(time.Now().Add(time.Hour)))
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("exported %d spans before the root ended, want 0", n)
+	}
+	root.End()
+	p.decide(p.expire(time.Now()))
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("exported %d spans right after the root ended, want 0", n)
+	}
+	p.decide(p.expire(time.Now().Add(p.window)))
+	if n := len(rec.Ended()); n != 2 {
+		t.Fatalf("exported %d spans a window after the root ended, want 2", n)
+	}
+}
+
+func TestTailSamplingProbabilisticPolicy(t *testing.T) {
+	policies, err := parseTailPolicies("probabilistic=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	low := &tailTrace{id: trace.TraceID{0x00, 1, 2, 3, 4, 5, 6, 7, 0xff}}
+	high := &tailTrace{id: trace.TraceID{0xff, 1, 2, 3, 4, 5, 6, 7, 0x00}}
+	if !policies[0].match(low) || policies[0].match(high) {
+		t.Fatal("probabilistic policy doesn't decide on the trace ID like TraceIDRatioBased")
+	}
+}
+
+func TestParseTailPolicies(t *testing.T) {
+	for _, raw := range []string{"latency=fast", "attribute=status", "probabilistic=2", "unknown"} {
+		if _, err := parseTailPolicies(raw); err == nil {
+			t.Errorf("parseTailPolicies(%q) accepted a bad policy", raw)
+		}
+	}
+}
@@ -1,53 +1,172 @@
 package telemetry
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/morzhanov/go-otel/internal/config"
 	"github.com/morzhanov/go-otel/internal/telemetry/meter"
-	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
 type TraceFn func(name string, opts ...trace.TracerOption) trace.Tracer
 
 type telemetry struct {
-	tp TraceFn
-	mp meter.Meter
+	tp       TraceFn
+	tpsdk    *tracesdk.TracerProvider
+	mp       meter.Meter
+	sampler  *Sampler
+	baggage  *Baggage
+	redactor *Redactor
+	health   *Health
+	prop     propagation.TextMapPropagator
+	admin    *AdminServer
 }
 
 type Telemetry interface {
 	Tracer() TraceFn
 	Meter() meter.Meter
+	Sampler() *Sampler
+	Baggage() *Baggage
+	Redactor() *Redactor
+	Health() *Health
+	// Propagator is the propagator of the service, it is also installed
+	// globally by NewTelemetry.
+	Propagator() propagation.TextMapPropagator
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
 }
 
-func tracerProvider(url string, service string) (TraceFn, error) {
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
+func tracerProvider(c *config.Config, res *resource.Resource, sampler *Sampler, b *Baggage, r *Redactor, h *Health, m meter.Meter) (*tracesdk.TracerProvider, error) {
+	processors, err := NewSpanProcessors(context.Background(), c, h, m)
 	if err != nil {
 		return nil, err
 	}
-	tp := tracesdk.NewTracerProvider(
-		tracesdk.WithBatcher(exp),
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(service),
-		)),
-	)
-	return tp.Tracer, nil
+	opts := []tracesdk.TracerProviderOption{
+		tracesdk.WithSampler(sampler),
+		tracesdk.WithResource(res),
+	}
+	if len(b.AttributeKeys()) > 0 {
+		opts = append(opts, tracesdk.WithSpanProcessor(baggageSpanProcessor{b}))
+	}
+	if c.TailSamplingPolicies != "" {
+		next := make([]tracesdk.SpanProcessor, 0, len(processors))
+		for _, p := range processors {
+			next = append(next, r.SpanProcessor(p))
+		}
+		tail, err := newTailSpanProcessor(c, next, m)
+		if err != nil {
+			for _, p := range processors {
+				_ = p.Shutdown(context.Background())
+			}
+			return nil, err
+		}
+		return tracesdk.NewTracerProvider(append(opts, tracesdk.WithSpanProcessor(tail))...), nil
+	}
+	for _, p := range processors {
+		opts = append(opts, tracesdk.WithSpanProcessor(errorSpanProcessor{SpanProcessor: r.SpanProcessor(p), sampler: sampler}))
+	}
+	return tracesdk.NewTracerProvider(opts...), nil
+}
+
+func (t *telemetry) Tracer() TraceFn     { return t.tp }
+func (t *telemetry) Meter() meter.Meter  { return t.mp }
+func (t *telemetry) Sampler() *Sampler   { return t.sampler }
+func (t *telemetry) Baggage() *Baggage   { return t.baggage }
+func (t *telemetry) Redactor() *Redactor { return t.redactor }
+func (t *telemetry) Health() *Health     { return t.health }
+
+func (t *telemetry) Propagator() propagation.TextMapPropagator { return t.prop }
+
+func (t *telemetry) ForceFlush(ctx context.Context) (err error) {
+	if ferr := t.tpsdk.ForceFlush(ctx); ferr != nil {
+		err = multierr.Append(err, fmt.Errorf("tracer provider flush: %w", ferr))
+	}
+	if ferr := t.mp.ForceFlush(ctx); ferr != nil {
+		err = multierr.Append(err, fmt.Errorf("meter provider flush: %w", ferr))
+	}
+	return err
 }
 
-func (t *telemetry) Tracer() TraceFn    { return t.tp }
-func (t *telemetry) Meter() meter.Meter { return t.mp }
+func (t *telemetry) Shutdown(ctx context.Context) (err error) {
+	if serr := t.tpsdk.Shutdown(ctx); serr != nil {
+		err = multierr.Append(err, fmt.Errorf("tracer provider shutdown: %w", serr))
+	}
+	if serr := t.mp.Shutdown(ctx); serr != nil {
+		err = multierr.Append(err, fmt.Errorf("meter provider shutdown: %w", serr))
+	}
+	if t.admin != nil {
+		if serr := t.admin.Shutdown(ctx); serr != nil {
+			err = multierr.Append(err, fmt.Errorf("admin server shutdown: %w", serr))
+		}
+	}
+	return err
+}
 
-func NewTelemetry(url string, service string, log *zap.Logger) (Telemetry, error) {
-	tp, err := tracerProvider(url, service)
+func NewTelemetry(c *config.Config, service string, log *zap.Logger) (Telemetry, error) {
+	bg, err := NewBaggage(c)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := NewPropagator(c.TracePropagators, bg, service)
 	if err != nil {
 		return nil, err
 	}
-	mtr, err := meter.NewMeter(log)
+	otel.SetTextMapPropagator(prop)
+	redactor, err := NewRedactor(c)
 	if err != nil {
 		return nil, err
 	}
-	return &telemetry{tp: tp, mp: mtr}, nil
+	scfg, err := NewSamplingConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	sampler, err := NewSampler(scfg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := NewResource(context.Background(), c, service)
+	if err != nil {
+		if res == nil {
+			return nil, err
+		}
+		log.Warn("partial telemetry resource detected", zap.Error(err))
+	}
+	mtr, err := meter.NewMeter(c, log, res)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetErrorHandler(newErrorHandler(log, mtr))
+	health := NewHealth()
+	health.Watch("metrics", mtr.CollectError)
+	mtr.Handle("/ready", health)
+	if err := health.register(mtr); err != nil {
+		_ = mtr.Shutdown(context.Background())
+		return nil, err
+	}
+	tp, err := tracerProvider(c, res, sampler, bg, redactor, health, mtr)
+	if err != nil {
+		_ = mtr.Shutdown(context.Background())
+		return nil, err
+	}
+	if c.SpanMetrics {
+		tp.RegisterSpanProcessor(newSpanMetricsProcessor(c, service, mtr))
+	}
+	t := &telemetry{tp: tp.Tracer, tpsdk: tp, mp: mtr, sampler: sampler, baggage: bg, redactor: redactor, health: health, prop: prop}
+	if c.TelemetryAdminAddr != "" {
+		if t.admin, err = NewAdminServer(c.TelemetryAdminAddr, log); err != nil {
+			_ = t.Shutdown(context.Background())
+			return nil, err
+		}
+		t.admin.Handle("/sampling", sampler)
+		t.admin.Handle("/ready", health)
+		t.admin.Handle(mtr.HandlerPath(), mtr.Handler())
+	}
+	return t, nil
 }
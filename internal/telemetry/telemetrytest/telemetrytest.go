@@ -0,0 +1,264 @@
+// Package telemetrytest provides a Telemetry that keeps spans and metrics in
+// memory, so handlers can be checked for correct instrumentation in tests.
+package telemetrytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morzhanov/go-otel/internal/config"
+	"github.com/morzhanov/go-otel/internal/telemetry"
+	"github.com/morzhanov/go-otel/internal/telemetry/meter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+var _ telemetry.Telemetry = (*Telemetry)(nil)
+
+// Telemetry implements telemetry.Telemetry, every span is sampled and kept
+// by Recorder once ended, metrics are read from Controller after Collect.
+type Telemetry struct {
+	Recorder   *tracetest.SpanRecorder
+	Controller *controller.Controller
+	tp         *tracesdk.TracerProvider
+	sp         tracesdk.SpanProcessor
+	mp         meter.Meter
+	sampler    *telemetry.Sampler
+	baggage    *telemetry.Baggage
+	redactor   *telemetry.Redactor
+	health     *telemetry.Health
+	prop       propagation.TextMapPropagator
+}
+
+// New builds the test Telemetry with the default propagators, c may be nil,
+// only the baggage and redaction settings are taken from it. Nothing is
+// installed globally, so tests can run in parallel.
+func New(c *config.Config) (*Telemetry, error) {
+	if c == nil {
+		c = &config.Config{}
+	}
+	sampler, err := telemetry.NewSampler(telemetry.SamplingConfig{Sampler: telemetry.SamplerAlways})
+	if err != nil {
+		return nil, err
+	}
+	bg, err := telemetry.NewBaggage(c)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := telemetry.NewPropagator("", bg, "")
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := telemetry.NewRedactor(c)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := tracetest.NewSpanRecorder()
+	sp := redactor.SpanProcessor(rec)
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(sampler),
+		tracesdk.WithSpanProcessor(sp),
+	)
+	mp, ctrl := meter.NewManualMeter(zap.NewNop(), resource.Empty())
+	return &Telemetry{
+		Recorder:   rec,
+		Controller: ctrl,
+		tp:         tp,
+		sp:         sp,
+		mp:         mp,
+		sampler:    sampler,
+		baggage:    bg,
+		redactor:   redactor,
+		health:     telemetry.NewHealth(),
+		prop:       prop,
+	}, nil
+}
+
+func (t *Telemetry) Tracer() telemetry.TraceFn     { return t.tp.Tracer }
+func (t *Telemetry) Meter() meter.Meter            { return t.mp }
+func (t *Telemetry) Sampler() *telemetry.Sampler   { return t.sampler }
+func (t *Telemetry) Baggage() *telemetry.Baggage   { return t.baggage }
+func (t *Telemetry) Redactor() *telemetry.Redactor { return t.redactor }
+func (t *Telemetry) Health() *telemetry.Health     { return t.health }
+
+func (t *Telemetry) Propagator() propagation.TextMapPropagator { return t.prop }
+
+func (t *Telemetry) ForceFlush(ctx context.Context) error {
+	return multierr.Append(t.tp.ForceFlush(ctx), t.mp.ForceFlush(ctx))
+}
+
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	return multierr.Append(t.tp.Shutdown(ctx), t.mp.Shutdown(ctx))
+}
+
+// Spans returns the ended spans in the order they ended.
+func (t *Telemetry) Spans() []tracesdk.ReadOnlySpan {
+	return t.Recorder.Ended()
+}
+
+// Reset drops the recorded spans.
+func (t *Telemetry) Reset() {
+	rec := tracetest.NewSpanRecorder()
+	sp := t.redactor.SpanProcessor(rec)
+	t.tp.RegisterSpanProcessor(sp)
+	t.tp.UnregisterSpanProcessor(t.sp)
+	t.Recorder, t.sp = rec, sp
+}
+
+// Span returns the first ended span with the given name and fails the test if
+// there is none.
+func (t *Telemetry) Span(tb testing.TB, name string) tracesdk.ReadOnlySpan {
+	tb.Helper()
+	for _, s := range t.Spans() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	tb.Fatalf("span %q not recorded, got %v", name, t.SpanNames())
+	return nil
+}
+
+// SpanNames returns the names of the ended spans.
+func (t *Telemetry) SpanNames() []string {
+	var names []string
+	for _, s := range t.Spans() {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+// AssertSpans checks that every name was recorded.
+func (t *Telemetry) AssertSpans(tb testing.TB, names ...string) {
+	tb.Helper()
+	for _, n := range names {
+		t.Span(tb, n)
+	}
+}
+
+// AssertParent checks that child is a direct child of parent in the same trace.
+func (t *Telemetry) AssertParent(tb testing.TB, child, parent string) {
+	tb.Helper()
+	c, p := t.Span(tb, child), t.Span(tb, parent)
+	if c.Parent().SpanID() != p.SpanContext().SpanID() || c.SpanContext().TraceID() != p.SpanContext().TraceID() {
+		tb.Errorf("span %q: parent is %s, want %q (%s)", child, c.Parent().SpanID(), parent, p.SpanContext().SpanID())
+	}
+}
+
+// AssertAttribute checks that the span has the attribute with the given value.
+func (t *Telemetry) AssertAttribute(tb testing.TB, name string, kv attribute.KeyValue) {
+	tb.Helper()
+	s := t.Span(tb, name)
+	for _, a := range s.Attributes() {
+		if a.Key != kv.Key {
+			continue
+		}
+		if a.Value != kv.Value {
+			tb.Errorf("span %q: %s = %s, want %s", name, kv.Key, a.Value.Emit(), kv.Value.Emit())
+		}
+		return
+	}
+	tb.Errorf("span %q: attribute %s not set", name, kv.Key)
+}
+
+// AssertStatus checks the span status code.
+func (t *Telemetry) AssertStatus(tb testing.TB, name string, code codes.Code) {
+	tb.Helper()
+	if got := t.Span(tb, name).Status().Code; got != code {
+		tb.Errorf("span %q: status %s, want %s", name, got, code)
+	}
+}
+
+// AssertKind checks the span kind.
+func (t *Telemetry) AssertKind(tb testing.TB, name string, kind trace.SpanKind) {
+	tb.Helper()
+	if got := t.Span(tb, name).SpanKind(); got != kind {
+		tb.Errorf("span %q: kind %s, want %s", name, got, kind)
+	}
+}
+
+// Collect runs a metric collection, call it before reading metrics.
+func (t *Telemetry) Collect(tb testing.TB) {
+	tb.Helper()
+	if err := t.Controller.Collect(context.Background()); err != nil {
+		tb.Fatalf("collect metrics: %v", err)
+	}
+}
+
+func (t *Telemetry) record(tb testing.TB, name string, attrs []attribute.KeyValue) export.Record {
+	tb.Helper()
+	var (
+		rec   export.Record
+		found bool
+	)
+	err := t.Controller.ForEach(export.CumulativeExportKindSelector(), func(r export.Record) error {
+		if found || r.Descriptor().Name() != name {
+			return nil
+		}
+		labels := r.Labels()
+		for _, kv := range attrs {
+			if v, ok := labels.Value(kv.Key); !ok || v != kv.Value {
+				return nil
+			}
+		}
+		rec, found = r, true
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("read metrics: %v", err)
+	}
+	if !found {
+		tb.Fatalf("metric %q with %v not collected", name, attrs)
+	}
+	return rec
+}
+
+// MetricValue returns the value of a counter or gauge whose labels include
+// attrs.
+func (t *Telemetry) MetricValue(tb testing.TB, name string, attrs ...attribute.KeyValue) float64 {
+	tb.Helper()
+	rec := t.record(tb, name, attrs)
+	kind := rec.Descriptor().NumberKind()
+	switch agg := rec.Aggregation().(type) {
+	case aggregation.Sum:
+		n, err := agg.Sum()
+		if err != nil {
+			tb.Fatalf("metric %q: %v", name, err)
+		}
+		return n.CoerceToFloat64(kind)
+	case aggregation.LastValue:
+		n, _, err := agg.LastValue()
+		if err != nil {
+			tb.Fatalf("metric %q: %v", name, err)
+		}
+		return n.CoerceToFloat64(kind)
+	}
+	tb.Fatalf("metric %q: unsupported aggregation %s", name, rec.Aggregation().Kind())
+	return 0
+}
+
+// HistogramCount returns the number of observations of a histogram whose
+// labels include attrs.
+func (t *Telemetry) HistogramCount(tb testing.TB, name string, attrs ...attribute.KeyValue) uint64 {
+	tb.Helper()
+	rec := t.record(tb, name, attrs)
+	agg, ok := rec.Aggregation().(aggregation.Count)
+	if !ok {
+		tb.Fatalf("metric %q: unsupported aggregation %s", name, rec.Aggregation().Kind())
+	}
+	n, err := agg.Count()
+	if err != nil {
+		tb.Fatalf("metric %q: %v", name, err)
+	}
+	return n
+}